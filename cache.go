@@ -0,0 +1,232 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// ErrCacheMiss - Error returned when response is not found in cache.
+var ErrCacheMiss = errors.New("crawl: cache miss")
+
+// ErrNoCache - Error returned in offline mode when cache is not set.
+var ErrNoCache = errors.New("crawl: offline mode without cache")
+
+// Cache - HTTP responses cache storage.
+// Responses are stored by request fingerprint.
+type Cache interface {
+	// Get - Gets cached value. Returns ErrCacheMiss if not found.
+	Get(key string) ([]byte, error)
+
+	// Set - Stores a value in cache.
+	Set(key string, value []byte) error
+}
+
+// NewFileCache - Creates a cache storing responses in directory.
+func NewFileCache(dir string) Cache {
+	return &fileCache{dir: dir}
+}
+
+type fileCache struct {
+	dir string
+}
+
+func (cache *fileCache) Get(key string) (body []byte, err error) {
+	body, err = ioutil.ReadFile(cache.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrCacheMiss
+	}
+	return
+}
+
+// Set - Writes value to a temporary file and renames it.
+func (cache *fileCache) Set(key string, value []byte) (err error) {
+	fname := cache.path(key)
+	if err = os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(filepath.Dir(fname), "tmp-")
+	if err != nil {
+		return
+	}
+	if _, err = f.Write(value); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	return os.Rename(f.Name(), fname)
+}
+
+func (cache *fileCache) path(key string) string {
+	if len(key) > 2 {
+		return filepath.Join(cache.dir, key[:2], key)
+	}
+	return filepath.Join(cache.dir, key)
+}
+
+// cachedResponse - Response as it is stored in cache.
+type cachedResponse struct {
+	URL        string      `json:"url,omitempty"`
+	Status     string      `json:"status,omitempty"`
+	StatusCode int         `json:"status_code,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	Time       time.Time   `json:"time,omitempty"`
+}
+
+// response - Constructs http response for a request.
+func (cached *cachedResponse) response(httpReq *http.Request) (resp *http.Response, err error) {
+	u, err := url.Parse(cached.URL)
+	if err != nil {
+		return
+	}
+	r := *httpReq
+	r.URL = u
+	return &http.Response{
+		Status:        cached.Status,
+		StatusCode:    cached.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cached.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       &r,
+	}, nil
+}
+
+// fetch - Makes http request using cache if it is set.
+// Cached response is validated using ETag and Last-Modified headers.
+// In offline mode responses are served only from cache.
+// Stream requests are not cached so their body is not read in advance.
+// Failure to store response in cache is logged and does not fail request.
+func (crawl *crawl) fetch(ctx context.Context, client *http.Client, req *Request, httpReq *http.Request) (_ *http.Response, err error) {
+	if crawl.opts.offline {
+		return crawl.fetchOffline(req, httpReq)
+	}
+	if crawl.cache == nil || req.Stream {
+		return ctxhttp.Do(ctx, client, httpReq)
+	}
+
+	key, err := req.Fingerprint()
+	if err != nil {
+		return
+	}
+	cached, err := crawl.cacheGet(key)
+	if err == ErrCacheMiss {
+		err = nil
+	} else if err != nil {
+		return
+	}
+
+	if cached != nil {
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			httpReq.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			httpReq.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	resp, err := ctxhttp.Do(ctx, client, httpReq)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		return cached.response(httpReq)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	// Read body to store response in cache, bodies exceeding maximum
+	// size are not cached and the limit is enforced by response reader
+	reader := io.Reader(resp.Body)
+	limit := crawl.maxBodySize(req)
+	if limit > 0 {
+		reader = io.LimitReader(resp.Body, limit+1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		resp.Body.Close()
+		return
+	}
+	if limit > 0 && int64(len(body)) > limit {
+		resp.Body = &readCloser{
+			Reader: io.MultiReader(bytes.NewReader(body), resp.Body),
+			Closer: resp.Body,
+		}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	value, err := json.Marshal(&cachedResponse{
+		URL:        resp.Request.URL.String(),
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		Time:       time.Now(),
+	})
+	if err == nil {
+		err = crawl.cache.Set(key, value)
+	}
+	if err != nil {
+		crawl.logger.Log(LevelWarning, "cache error", requestFields(req, F("error", err))...)
+	}
+	return resp, nil
+}
+
+// fetchOffline - Returns response from cache.
+// Returns ErrNoCache if cache is not set and ErrCacheMiss if response
+// is not found in cache.
+func (crawl *crawl) fetchOffline(req *Request, httpReq *http.Request) (_ *http.Response, err error) {
+	if crawl.cache == nil {
+		return nil, ErrNoCache
+	}
+	key, err := req.Fingerprint()
+	if err != nil {
+		return
+	}
+	cached, err := crawl.cacheGet(key)
+	if err != nil {
+		return
+	}
+	return cached.response(httpReq)
+}
+
+// readCloser - Body reader closing underlying response body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// cacheGet - Gets response from cache.
+func (crawl *crawl) cacheGet(key string) (cached *cachedResponse, err error) {
+	value, err := crawl.cache.Get(key)
+	if err != nil {
+		return
+	}
+	cached = new(cachedResponse)
+	if err = json.Unmarshal(value, cached); err != nil {
+		return nil, err
+	}
+	return
+}
@@ -0,0 +1,124 @@
+package crawl
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// TestCache -
+func TestCache(t *testing.T) {
+	var fetched, revalidated int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetched++
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "<html><body>cached</body></html>")
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "crawl-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache := NewFileCache(dir)
+	for _, c := range []Crawler{New(WithCache(cache)), New(WithCache(cache)), New(WithCache(cache), WithOffline())} {
+		resp, err := c.Execute(context.Background(), &Request{URL: ts.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text := Text(resp, "body"); text != "cached" {
+			t.Errorf("unexpected body %q", text)
+		}
+	}
+	if fetched != 1 || revalidated != 1 {
+		t.Errorf("expected 1 fetch and 1 revalidation, got %d and %d", fetched, revalidated)
+	}
+
+	_, err = New(WithCache(cache), WithOffline()).Execute(context.Background(), &Request{URL: ts.URL + "/missing"})
+	if !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("expected cache miss, got %v", err)
+	}
+}
+
+// TestCacheMaxBodySize -
+func TestCacheMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "crawl-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache := NewFileCache(dir)
+	_, err = New(WithCache(cache), WithMaxBodySize(10)).Execute(context.Background(), &Request{URL: ts.URL})
+	var sizeErr *BodySizeError
+	if !errors.As(err, &sizeErr) {
+		t.Errorf("expected body size error, got %v", err)
+	}
+
+	// Stream requests are not cached
+	var body []byte
+	c := New(WithCache(cache))
+	c.Register("stream", func(_ context.Context, resp *Response) (err error) {
+		body, err = ioutil.ReadAll(resp.Reader())
+		return
+	})
+	if _, err = c.Execute(context.Background(), &Request{URL: ts.URL + "/stream", Stream: true, Callbacks: Callbacks("stream")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 100 {
+		t.Errorf("unexpected stream body size %d", len(body))
+	}
+
+	offline := New(WithCache(cache), WithOffline())
+	for _, u := range []string{ts.URL, ts.URL + "/stream"} {
+		if _, err = offline.Execute(context.Background(), &Request{URL: u}); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("%s: expected cache miss, got %v", u, err)
+		}
+	}
+}
+
+type failingCache struct{}
+
+func (failingCache) Get(string) ([]byte, error) { return nil, ErrCacheMiss }
+func (failingCache) Set(string, []byte) error   { return errors.New("disk full") }
+
+// TestCacheErrors -
+func TestCacheErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>ok</body></html>")
+	}))
+	defer ts.Close()
+
+	resp, err := New(WithCache(failingCache{})).Execute(context.Background(), &Request{URL: ts.URL})
+	if err != nil {
+		t.Fatalf("expected cache error to be ignored, got %v", err)
+	}
+	if text := Text(resp, "body"); text != "ok" {
+		t.Errorf("unexpected body %q", text)
+	}
+
+	_, err = New(WithOffline()).Execute(context.Background(), &Request{URL: ts.URL})
+	if !errors.Is(err, ErrNoCache) {
+		t.Errorf("expected no cache error, got %v", err)
+	}
+}
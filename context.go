@@ -0,0 +1,33 @@
+package crawl
+
+import "golang.org/x/net/context"
+
+// responseKey - Context key of response handlers are executed with.
+type responseKey struct{}
+
+// callbackKey - Context key of callback name handler is executed for.
+type callbackKey struct{}
+
+// WithResponse - Sets response in context.
+// Context passed to handlers always contains a response.
+func WithResponse(ctx context.Context, resp *Response) context.Context {
+	return context.WithValue(ctx, responseKey{}, resp)
+}
+
+// ResponseFromContext - Returns response from context.
+func ResponseFromContext(ctx context.Context) (resp *Response, ok bool) {
+	resp, ok = ctx.Value(responseKey{}).(*Response)
+	return
+}
+
+// WithCallback - Sets callback name in context.
+// Context passed to handlers always contains a callback name.
+func WithCallback(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, callbackKey{}, name)
+}
+
+// CallbackFromContext - Returns callback name from context.
+func CallbackFromContext(ctx context.Context) (name string, ok bool) {
+	name, ok = ctx.Value(callbackKey{}).(string)
+	return
+}
@@ -1,12 +1,14 @@
 package crawl
 
 import (
+	"errors"
 	"io"
-	"math/rand"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptrace"
 	"net/url"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
@@ -14,8 +16,6 @@ import (
 	"github.com/ryanuber/go-glob"
 
 	"golang.org/x/net/context"
-	"golang.org/x/net/context/ctxhttp"
-	"golang.org/x/net/proxy"
 )
 
 // Handler - Crawler handler.
@@ -24,14 +24,31 @@ type Handler func(context.Context, *Response) error
 // Middleware - Crawler middleware.
 type Middleware func(context.Context, *Request, *http.Request) error
 
+// ResponseMiddleware - Crawler response middleware.
+// It is executed after response is received and before HTML parsing.
+type ResponseMiddleware func(context.Context, *Response) error
+
+// PanicHandler - Function called when a job panics.
+// It is called before panic error is sent to errors channel.
+type PanicHandler func(context.Context, *PanicError)
+
+// ErrSkipHandlers - Error returned by response middleware to skip
+// parsing and handlers of a response. It is not returned from Execute.
+var ErrSkipHandlers = errors.New("crawl: skip handlers")
+
 // Crawler - Crawler interface.
 type Crawler interface {
 	// Schedule - Schedules request.
 	// Context is passed to queue in a job.
+	// Request is silently dropped if it was already seen by deduplicator
+	// or if it exceeds maximum depth.
 	Schedule(context.Context, *Request) error
 
 	// Execute - Makes a http request respecting context deadline.
-	// If request Raw is not true - ParseHTML() method is executed on Response.
+	// Response is parsed in request Format, HTML is parsed if it is empty
+	// and format is detected from Content-Type if it is FormatAuto.
+	// JSON responses can be decoded by handlers using JSON() or JSONPath().
+	// If request Raw or Stream is true response is not parsed.
 	// Then all callbacks are executed with context.
 	Execute(context.Context, *Request) (*Response, error)
 
@@ -39,22 +56,47 @@ type Crawler interface {
 	Handlers() map[string][]Handler
 
 	// Register - Registers crawl handler.
-	Register(name string, h Handler)
+	// Options can limit responses handler is executed for.
+	Register(name string, h Handler, opts ...HandlerOption)
 
 	// Middleware - Registers a middleware.
 	// Request is not executed if middleware returns an error.
 	Middleware(Middleware)
 
+	// ResponseMiddleware - Registers a response middleware.
+	// Response is not parsed and handlers are not executed
+	// if middleware returns an error.
+	ResponseMiddleware(ResponseMiddleware)
+
 	// Start - Starts the crawler.
 	// All errors should be received from Errors() channel.
+	// Short for StartContext(context.Background()).
 	Start()
 
+	// StartContext - Starts the crawler and blocks until it is finished.
+	// It returns when the queue is closed, when queue implements IdleQueue
+	// and it is idle or after shutdown when context is canceled.
+	// Errors channel is closed when it returns.
+	StartContext(context.Context) error
+
+	// Shutdown - Gracefully stops the crawler. New jobs are not started
+	// and in-flight jobs are awaited until context is done, queue is closed
+	// after that. Handlers can schedule requests until the queue is closed.
+	Shutdown(context.Context) error
+
 	// Close - Closes the queue and the crawler.
+	// Items exporters are flushed when crawler is stopped.
 	Close() error
 
+	// Stats - Returns snapshot of crawler statistics.
+	Stats() *Stats
+
 	// Errors - Returns channel that will receive all crawl errors.
 	// Only errors from queued requests are here.
 	// Not only request errors but also queue errors.
+	// Request errors are *RequestError and queue errors are *QueueError,
+	// underlying typed errors can be matched using errors.As.
+	// Channel is closed when crawler is stopped.
 	Errors() <-chan error
 }
 
@@ -62,36 +104,65 @@ type Crawler interface {
 // a memory queue with a capacity of WithQueueCapacity seting value (default=10000).
 func New(opts ...Option) Crawler {
 	c := &crawl{
-		handlers:   make(map[string][]Handler),
-		errorsChan: make(chan error, 10000),
+		handlers:    make(map[string][]Handler),
+		errorsChan:  make(chan error, 10000),
+		errorsMutex: new(sync.RWMutex),
+		mutex:       new(sync.Mutex),
+		inFlight:    new(sync.WaitGroup),
+		stopChan:    make(chan struct{}),
+		forceChan:   make(chan struct{}),
+		doneChan:    make(chan struct{}),
+		forceOnce:   new(sync.Once),
+		stats:       newStats(),
+		logger:      NopLogger,
 		opts: &options{
-			concurrency:   1000,
-			queueCapacity: 10000,
-			headers:       DefaultHeaders,
+			concurrency:    1000,
+			queueCapacity:  10000,
+			hostQueueLimit: 1,
+			headers:        DefaultHeaders,
 		},
 	}
 	for _, opt := range opts {
 		opt(c)
 	}
 	if c.transport == nil {
-		c.transport = c.defaultTransport((&net.Dialer{
-			Timeout:   c.opts.defaultTimeout,
-			KeepAlive: c.opts.defaultTimeout,
-		}).Dial)
+		c.transport = c.defaultTransport(c.dialer().DialContext)
 	}
+	// Total timeout is applied by Execute so requests can overwrite it
 	c.client = &http.Client{
-		Timeout:   c.opts.defaultTimeout,
 		Transport: c.transport,
 	}
 	if c.client.Jar == nil {
 		c.client.Jar, _ = cookiejar.New(nil)
 	}
+	// Implicit pool does not detect bans so responses of request,
+	// context and crawler proxies reach handlers unchanged,
+	// its transports use crawler timeouts
+	if c.proxies == nil {
+		c.proxies, _ = NewProxyPool(nil,
+			WithProxyStrategy(ProxyRandom),
+			WithBanDetector(nil),
+			WithProxyTransport(c.proxyTransport),
+		)
+	}
 	if c.queue == nil {
 		c.queue = NewQueue(c.opts.queueCapacity)
 	}
+	// Throttle is required by robots.txt to honour Crawl-delay
+	if c.opts.hostConcurrency > 0 || c.opts.hostDelay > 0 || c.opts.hostJitter > 0 || c.opts.robotsAgent != "" {
+		c.throttle = newThrottle(c.opts.hostConcurrency, c.opts.hostDelay, c.opts.hostJitter)
+		c.throttle.maxHostWaiting = c.opts.hostQueueLimit
+		c.throttle.maxWaiting = c.opts.concurrency
+	}
+	if c.opts.robotsAgent != "" {
+		c.robots = newRobots(c, c.opts.robotsAgent)
+	}
 	return c
 }
 
+// idleCheckInterval - Interval of checking if IdleQueue is idle.
+var idleCheckInterval = 100 * time.Millisecond
+
 // DefaultHeaders - Default crawler headers.
 var DefaultHeaders = map[string]string{
 	"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8",
@@ -109,14 +180,196 @@ type crawl struct {
 
 	queue Queue
 
+	// throttle - per-host throttle, nil if disabled
+	throttle *throttle
+
+	// robots - robots.txt rules cache, nil if disabled
+	robots *robots
+
+	// dedup - requests deduplicator, nil if disabled
+	dedup Deduplicator
+
+	// retry - failed requests retry policy, nil if disabled
+	retry *RetryPolicy
+
+	// cache - http responses cache, nil if disabled
+	cache Cache
+
+	// stats - crawler statistics
+	stats *stats
+
+	// logger - crawler logger
+	logger Logger
+
+	// spans - spans exporter, nil if tracing is disabled
+	spans SpanExporter
+
+	// proxies - proxy pool, by default it is empty and proxies
+	// are selected randomly from context and crawler proxies
+	// without ban detection
+	proxies *ProxyPool
+
+	// panicHandler - called on recovered panics, nil if not set
+	panicHandler PanicHandler
+
+	// pipeline - scraped items pipeline, nil if disabled
+	pipeline *pipeline
+
 	// patterns - callbacks glob patterns
 	patterns []string
 
 	// middlewares - crawler middlewares.
 	middlewares []Middleware
+
+	// responseMiddlewares - crawler response middlewares.
+	responseMiddlewares []ResponseMiddleware
+
+	// errorsMutex - guards errorsChan from sending after close
+	errorsMutex  *sync.RWMutex
+	errorsClosed bool
+
+	// mutex - guards started, stopping and inFlight.Add()
+	mutex    *sync.Mutex
+	started  bool
+	stopping bool
+	// inFlight - jobs being executed
+	inFlight *sync.WaitGroup
+
+	// stopChan - closed when crawler is stopping
+	stopChan chan struct{}
+	// forceChan - closed when shutdown deadline is exceeded
+	forceChan chan struct{}
+	forceOnce *sync.Once
+	// doneChan - closed when crawler is stopped
+	doneChan chan struct{}
 }
 
 func (crawl *crawl) Start() {
+	crawl.StartContext(context.Background())
+}
+
+func (crawl *crawl) StartContext(ctx context.Context) (err error) {
+	crawl.mutex.Lock()
+	crawl.started = true
+	crawl.mutex.Unlock()
+
+	crawl.logger.Log(LevelInfo, "crawler started", F("concurrency", crawl.opts.concurrency))
+	defer crawl.logger.Log(LevelInfo, "crawler stopped")
+
+	workers := make(chan struct{})
+	go func() {
+		if crawl.throttle != nil {
+			crawl.startThrottled()
+		} else {
+			crawl.startWorkers()
+		}
+		close(workers)
+	}()
+
+	// Idle completion can be detected only if queue implements IdleQueue
+	var idle <-chan time.Time
+	queue, ok := crawl.queue.(IdleQueue)
+	if ok {
+		ticker := time.NewTicker(idleCheckInterval)
+		defer ticker.Stop()
+		idle = ticker.C
+	}
+
+	for done := false; !done; {
+		select {
+		case <-workers:
+			// Queue was closed
+			done = true
+		case <-idle:
+			// Retry is scheduled in the queue before failed job is done
+			if queue.Idle() {
+				crawl.queue.Close()
+				<-workers
+				done = true
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+			crawl.stop()
+			ctx = context.Background()
+		case <-crawl.stopChan:
+			crawl.drain(workers)
+			done = true
+		}
+	}
+
+	crawl.flushItems()
+	crawl.closeErrors()
+	close(crawl.doneChan)
+	return
+}
+
+func (crawl *crawl) Shutdown(ctx context.Context) error {
+	if !crawl.stop() {
+		crawl.flushItems()
+		crawl.closeErrors()
+		return crawl.queue.Close()
+	}
+	select {
+	case <-crawl.doneChan:
+		return nil
+	case <-ctx.Done():
+		crawl.forceOnce.Do(func() { close(crawl.forceChan) })
+		<-crawl.doneChan
+		return ctx.Err()
+	}
+}
+
+// drain - Waits for in-flight jobs and closes the queue.
+// Waiting is stopped when shutdown deadline is exceeded.
+func (crawl *crawl) drain(workers <-chan struct{}) {
+	inFlight := make(chan struct{})
+	go func() {
+		crawl.inFlight.Wait()
+		close(inFlight)
+	}()
+	select {
+	case <-inFlight:
+	case <-crawl.forceChan:
+	}
+	crawl.queue.Close()
+	// Workers skip remaining jobs after stop
+	// but they can be still running handlers when forced
+	select {
+	case <-workers:
+	case <-crawl.forceChan:
+	}
+}
+
+// stop - Stops accepting new jobs.
+// Returns false if crawler was not started.
+func (crawl *crawl) stop() bool {
+	crawl.mutex.Lock()
+	defer crawl.mutex.Unlock()
+	if !crawl.stopping {
+		crawl.stopping = true
+		close(crawl.stopChan)
+		// Jobs waiting for host limits would be skipped by workers
+		if crawl.throttle != nil {
+			crawl.throttle.Stop()
+		}
+	}
+	return crawl.started
+}
+
+// begin - Marks a job as in-flight.
+// Returns false if crawler is stopping and job should be skipped.
+func (crawl *crawl) begin() bool {
+	crawl.mutex.Lock()
+	defer crawl.mutex.Unlock()
+	if crawl.stopping {
+		return false
+	}
+	crawl.inFlight.Add(1)
+	return true
+}
+
+// startWorkers - Starts workers reading jobs from the queue.
+func (crawl *crawl) startWorkers() {
 	wg := new(sync.WaitGroup)
 	for i := 0; i < crawl.opts.concurrency; i++ {
 		wg.Add(1)
@@ -127,32 +380,225 @@ func (crawl *crawl) Start() {
 				if err == io.EOF {
 					return
 				} else if err != nil {
-					crawl.errorsChan <- err
+					crawl.logger.Log(LevelError, "queue error", F("error", err))
+					crawl.sendError(&QueueError{ErrorInfo: newErrorInfo(nil, time.Time{}), Op: "get", Err: err})
 					return
 				}
 
-				if _, err := crawl.Execute(job.Context(), job.Request()); err != nil {
-					crawl.errorsChan <- &RequestError{Err: err, Request: job.Request()}
-				}
+				crawl.process(job)
+			}
+		}()
+	}
+	wg.Wait()
+}
 
-				job.Done()
+// startThrottled - Starts the crawler with per-host throttling.
+// Jobs are read from the queue in a single goroutine and passed
+// to workers by throttle when host limits allow it. Reading is
+// blocked while throttle holds too many jobs waiting for hosts.
+func (crawl *crawl) startThrottled() {
+	wg := new(sync.WaitGroup)
+	for i := 0; i < crawl.opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range crawl.throttle.ready {
+				crawl.process(job)
+				crawl.throttle.Done(job)
 			}
 		}()
 	}
+	for {
+		job, err := crawl.queue.Get()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			crawl.logger.Log(LevelError, "queue error", F("error", err))
+			crawl.sendError(&QueueError{ErrorInfo: newErrorInfo(nil, time.Time{}), Op: "get", Err: err})
+			break
+		}
+		crawl.throttle.Add(job)
+	}
+	// Wait for throttled jobs to be sent to workers
+	crawl.throttle.Wait()
+	close(crawl.throttle.ready)
 	wg.Wait()
-	return
+}
+
+// process - Executes a job and marks it as done.
+// Failed request is retried if allowed by retry policy.
+// Job is skipped and not marked as done if crawler is stopping.
+func (crawl *crawl) process(job Job) {
+	if !crawl.wait(job.Request()) || !crawl.begin() {
+		return
+	}
+	defer crawl.inFlight.Done()
+	crawl.stats.add(func(stats *Stats) {
+		stats.Started++
+		stats.InFlight++
+	})
+	defer crawl.stats.add(func(stats *Stats) { stats.InFlight-- })
+
+	if _, err := crawl.execute(job); err != nil {
+		if _, panicked := err.(*PanicError); panicked || crawl.retry == nil || !crawl.retry.retry(job.Request(), err) {
+			if Enabled(crawl.logger, LevelWarning) {
+				crawl.logger.Log(LevelWarning, "request failed", requestFields(job.Request(), F("error", err))...)
+			}
+			crawl.fail(&RequestError{Err: err, Request: job.Request()})
+		} else {
+			crawl.scheduleRetry(job.Context(), job.Request(), err)
+		}
+	} else {
+		crawl.stats.add(func(stats *Stats) { stats.Completed++ })
+	}
+
+	job.Done()
+}
+
+// execute - Executes a job recovering from panics in handlers
+// and middlewares. Recovered panic is returned as *PanicError.
+func (crawl *crawl) execute(job Job) (resp *Response, err error) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			err = crawl.recovered(job.Context(), job.Request(), start, r)
+		}
+	}()
+	return crawl.Execute(job.Context(), job.Request())
+}
+
+// recovered - Counts and logs recovered panic and calls panic handler.
+func (crawl *crawl) recovered(ctx context.Context, req *Request, start time.Time, value interface{}) *PanicError {
+	err := &PanicError{
+		ErrorInfo: newErrorInfo(req, start),
+		Value:     value,
+		Stack:     debug.Stack(),
+	}
+	crawl.stats.add(func(stats *Stats) { stats.Panics++ })
+	crawl.logger.Log(LevelError, "request panic", requestFields(req, F("panic", value))...)
+	if crawl.panicHandler != nil {
+		crawl.panicHandler(ctx, err)
+	}
+	return err
+}
+
+// wait - Waits until request NotBefore time.
+// Returns false if crawler is stopping.
+func (crawl *crawl) wait(req *Request) bool {
+	if req.NotBefore == nil {
+		return true
+	}
+	d := time.Until(*req.NotBefore)
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-crawl.stopChan:
+		return false
+	}
+}
+
+// scheduleRetry - Schedules request retry in the queue, it is executed
+// after a backoff. Retry is scheduled before failed job is marked
+// as done so it is not lost when crawler stops during the backoff.
+// Request is not retried if backoff exceeds context deadline.
+func (crawl *crawl) scheduleRetry(ctx context.Context, req *Request, err error) {
+	retry := *req
+	retry.Attempt++
+	backoff := crawl.retry.backoff(req.Attempt)
+	notBefore := time.Now().Add(backoff)
+	if deadline, ok := ctx.Deadline(); ok && notBefore.After(deadline) {
+		crawl.fail(&RequestError{Err: context.DeadlineExceeded, Request: req})
+		return
+	}
+	retry.NotBefore = &notBefore
+	if Enabled(crawl.logger, LevelInfo) {
+		crawl.logger.Log(LevelInfo, "request retry", requestFields(req, F("backoff", backoff), F("error", err))...)
+	}
+	if err := crawl.queue.Schedule(ctx, &retry); err != nil {
+		err = &QueueError{ErrorInfo: newErrorInfo(&retry, time.Time{}), Op: "schedule", Err: err}
+		crawl.fail(&RequestError{Err: err, Request: &retry})
+		return
+	}
+	crawl.stats.add(func(stats *Stats) { stats.Retried++ })
+}
+
+// fail - Counts failed request and sends error to errors channel.
+func (crawl *crawl) fail(err error) {
+	crawl.stats.add(func(stats *Stats) { stats.Failed++ })
+	crawl.sendError(err)
+}
+
+// sendError - Sends error to errors channel.
+// Error is dropped if the channel is already closed.
+func (crawl *crawl) sendError(err error) {
+	crawl.errorsMutex.RLock()
+	defer crawl.errorsMutex.RUnlock()
+	if !crawl.errorsClosed {
+		crawl.errorsChan <- err
+	}
+}
+
+// closeErrors - Closes errors channel.
+func (crawl *crawl) closeErrors() {
+	crawl.errorsMutex.Lock()
+	defer crawl.errorsMutex.Unlock()
+	if !crawl.errorsClosed {
+		crawl.errorsClosed = true
+		close(crawl.errorsChan)
+	}
 }
 
 func (crawl *crawl) Execute(ctx context.Context, req *Request) (resp *Response, err error) {
+	started := time.Now()
+
 	// Get http.Request structure
 	httpReq, err := ConstructHTTPRequest(req)
 	if err != nil {
-		return
+		return nil, &FetchError{ErrorInfo: newErrorInfo(req, started), Phase: PhaseRequest, Err: err}
+	}
+
+	// Start request span, parent is request or context trace
+	parent := req.Trace
+	if parent == nil {
+		parent, _ = TraceFromContext(ctx)
+	}
+	span := crawl.startSpan(parent, "crawl.request")
+	if span != nil {
+		defer func() { crawl.endSpan(span, err) }()
+		span.SetAttribute("url", req.URL)
+		span.SetAttribute("method", req.GetMethod())
+		span.SetAttribute("attempt", req.Attempt)
+		ctx = WithTrace(ctx, span.Context())
+	}
+
+	// Request timeout is applied to fetching and reading the response
+	// but not to the context of handlers so it is not inherited
+	// by requests scheduled from handlers
+	fetchCtx := ctx
+	if timeout := crawl.requestTimeout(req); timeout > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if span != nil {
+		fetchCtx = httptrace.WithClientTrace(fetchCtx, crawl.clientTrace(span))
 	}
 
 	// Run request middlewares
-	for _, middleware := range crawl.middlewares {
+	for i, middleware := range crawl.middlewares {
 		if err = middleware(ctx, req, httpReq); err != nil {
+			return nil, &MiddlewareError{ErrorInfo: newErrorInfo(req, started), Index: i, Err: err}
+		}
+	}
+
+	// Check if request is allowed by robots.txt
+	if crawl.robots != nil {
+		if err = crawl.robots.Check(fetchCtx, req, httpReq.URL); err != nil {
 			return
 		}
 	}
@@ -164,89 +610,220 @@ func (crawl *crawl) Execute(ctx context.Context, req *Request) (resp *Response,
 		}
 	}
 
-	client := crawl.client
-	if addrs, ok := ProxyFromContext(ctx); ok && len(addrs) > 0 {
-		transport, err := crawl.transportFromProxies(addrs)
-		if err != nil {
-			return nil, err
-		}
+	client, proxy, err := crawl.proxyClient(ctx, req, httpReq.URL.Host)
+	if err != nil {
+		return
+	}
 
-		client = &http.Client{
-			Timeout:   crawl.opts.defaultTimeout,
-			Transport: transport,
+	// Log fields are built only if debug messages are logged
+	start := time.Now()
+	debug := Enabled(crawl.logger, LevelDebug)
+	var fields []Field
+	if proxy != nil {
+		if debug {
+			fields = append(fields, F("proxy", proxy.addr))
 		}
+		span.SetAttribute("proxy", proxy.addr)
+	}
+	if debug {
+		crawl.logger.Log(LevelDebug, "request started", requestFields(req, fields...)...)
 	}
 
-	httpResp, err := ctxhttp.Do(ctx, client, httpReq)
+	httpResp, err := crawl.fetch(fetchCtx, client, req, httpReq)
 	if err != nil {
-		return
+		if proxy != nil {
+			crawl.proxies.failure(proxy)
+		}
+		if debug {
+			crawl.logger.Log(LevelDebug, "request error", requestFields(req, append(fields, F("error", err), durationField(start))...)...)
+		}
+		return nil, &FetchError{ErrorInfo: newErrorInfo(req, started), Phase: fetchPhase(err), Err: err}
+	}
+	crawl.stats.response(httpReq.URL.Host, httpResp.StatusCode, time.Since(start))
+	span.SetAttribute("status", httpResp.StatusCode)
+	if debug {
+		crawl.logger.Log(LevelDebug, "response received", requestFields(req, append(fields, F("status", httpResp.StatusCode), durationField(start))...)...)
+	}
+
+	// Retryable status is an error when request will be retried,
+	// response of the final attempt is routed to error callback
+	if crawl.retry != nil && isRetryableStatus(httpResp.StatusCode) {
+		statusErr := &StatusError{
+			ErrorInfo:  newErrorInfo(req, started),
+			StatusCode: httpResp.StatusCode,
+			Status:     httpResp.Status,
+			Response:   httpResp,
+		}
+		if crawl.retry.retry(req, statusErr) {
+			httpResp.Body.Close()
+			if proxy != nil {
+				crawl.proxies.failure(proxy)
+			}
+			return nil, statusErr
+		}
 	}
 
 	resp = &Response{
-		Response: httpResp,
-		Request:  req,
+		Response:    httpResp,
+		Request:     req,
+		maxBodySize: crawl.maxBodySize(req),
+		fetchTime:   time.Now(),
 	}
 	defer resp.Close()
+	defer func(resp *Response) {
+		crawl.stats.add(func(stats *Stats) { stats.BytesDownloaded += resp.bytesRead })
+	}(resp)
 
-	// Parse HTML if not request.Raw
-	if !req.Raw {
-		err = resp.ParseHTML()
-		if err != nil {
-			return
+	// Fail early if announced body size exceeds limit
+	if resp.maxBodySize > 0 && httpResp.ContentLength > resp.maxBodySize {
+		return nil, &FetchError{ErrorInfo: newErrorInfo(req, started), Phase: PhaseBody, Err: &BodySizeError{Limit: resp.maxBodySize}}
+	}
+
+	// Run response middlewares
+	for i, middleware := range crawl.responseMiddlewares {
+		if err = middleware(ctx, resp); err == ErrSkipHandlers {
+			if proxy != nil {
+				crawl.proxies.success(proxy)
+			}
+			return resp, nil
+		} else if err != nil {
+			return nil, &MiddlewareError{ErrorInfo: newErrorInfo(req, started), Index: i, Response: true, Err: err}
 		}
 	}
 
-	if err = crawl.executeHandlers(ctx, resp); err != nil {
+	// Read body before parsing to distinguish read and parse errors
+	if format := resp.Format(); format != FormatRaw {
+		if _, err = resp.Bytes(); err != nil {
+			phase := PhaseBody
+			if fetchPhase(err) == PhaseTimeout {
+				phase = PhaseTimeout
+			}
+			return nil, &FetchError{ErrorInfo: newErrorInfo(req, started), Phase: phase, Err: err}
+		}
+		if err = resp.parse(); err != nil {
+			return nil, &ParseError{ErrorInfo: newErrorInfo(req, started), Format: format, Err: err}
+		}
+	}
+
+	// Check if proxy was banned
+	if proxy != nil {
+		if err = crawl.proxies.check(proxy, resp); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = crawl.executeHandlers(ctx, resp, started); err != nil {
 		return nil, err
 	}
 
 	return
 }
 
-func (crawl *crawl) transportFromProxies(addrs []string) (_ *http.Transport, err error) {
-	// Get a random proxy address from the list
-	addr := addrs[rand.Intn(len(addrs))]
-
-	// Parse proxy URL
-	proxyURL, err := url.Parse(addr)
-	if err != nil {
-		return
+// requestProxies - Returns proxies request can be executed through.
+// Returns nil if proxy has to be selected from the pool.
+func (crawl *crawl) requestProxies(ctx context.Context, req *Request) []string {
+	if req.Proxy != "" {
+		return []string{req.Proxy}
 	}
+	if addrs, ok := ProxyFromContext(ctx); ok && len(addrs) > 0 {
+		return addrs
+	}
+	return crawl.opts.proxies
+}
 
-	// Create a proxy dialer
-	proxy, err := proxy.FromURL(proxyURL, proxy.Direct)
-	if err != nil {
-		return
+// proxyClient - Selects proxy for a request to host from request, context,
+// crawler proxies or proxy pool and returns http client using it.
+// Returns crawler client and nil proxy if there are no proxies.
+func (crawl *crawl) proxyClient(ctx context.Context, req *Request, host string) (*http.Client, *proxyState, error) {
+	proxy, err := crawl.proxies.pick(host, crawl.requestProxies(ctx, req))
+	if err != nil || proxy == nil {
+		return crawl.client, nil, err
+	}
+	// Proxy client shares cookies with crawler client
+	client := &http.Client{
+		Transport: proxy.transport,
+		Jar:       crawl.client.Jar,
 	}
+	return client, proxy, nil
+}
 
-	// Make a http.Transport that uses the proxy dialer
-	return crawl.defaultTransport(proxy.Dial), nil
+// proxyTransport - Creates transport for a proxy with crawler timeouts.
+func (crawl *crawl) proxyTransport(u *url.URL) (*http.Transport, error) {
+	return proxyTransport(u, crawl.dialer(), crawl.defaultTransport)
 }
 
-func (crawl *crawl) executeHandlers(ctx context.Context, resp *Response) (err error) {
-	handlers := crawl.getHandlers(resp.Request.Callbacks)
+func (crawl *crawl) executeHandlers(ctx context.Context, resp *Response, started time.Time) (err error) {
+	handlers := crawl.getHandlers(crawl.routeCallbacks(resp))
 	if len(handlers) == 0 {
 		return
 	}
+	ctx = WithResponse(ctx, resp)
+	if crawl.pipeline != nil {
+		ctx = withPipeline(ctx, crawl.pipeline)
+	}
+	trace, _ := TraceFromContext(ctx)
 	for _, handler := range handlers {
-		if err = handler(ctx, resp); err != nil {
-			return
+		start := time.Now()
+		span := crawl.startSpan(trace, "crawl.handler")
+		hctx := WithCallback(ctx, handler.callback)
+		if span != nil {
+			span.SetAttribute("callback", handler.callback)
+			hctx = WithTrace(hctx, span.Context())
+		}
+		err = handler.handler(hctx, resp)
+		crawl.stats.handler(handler.callback, time.Since(start))
+		crawl.endSpan(span, err)
+		if err != nil {
+			return &HandlerError{
+				ErrorInfo: newErrorInfo(resp.Request, started),
+				Callback:  handler.callback,
+				Index:     handler.index,
+				Err:       err,
+			}
 		}
 	}
 	return
 }
 
-func (crawl *crawl) getHandlers(callbacks []string) (list []Handler) {
+// routeCallbacks - Returns callbacks for a response.
+// Responses with 4xx and 5xx status code are routed only to
+// request error callback or crawler default error callback if any.
+func (crawl *crawl) routeCallbacks(resp *Response) []string {
+	if resp.StatusCode < 400 {
+		return resp.Request.Callbacks
+	}
+	if resp.Request.OnError != "" {
+		return Callbacks(resp.Request.OnError)
+	}
+	if crawl.opts.errorCallback != "" {
+		return Callbacks(crawl.opts.errorCallback)
+	}
+	return resp.Request.Callbacks
+}
+
+// callbackHandler - Handler with a name of callback it was matched for
+// and its index in handlers registered under the name or pattern.
+type callbackHandler struct {
+	callback string
+	handler  Handler
+	index    int
+}
+
+func (crawl *crawl) getHandlers(callbacks []string) (list []callbackHandler) {
 	for _, pattern := range crawl.patterns {
 		for _, name := range callbacks {
 			if glob.Glob(pattern, name) {
-				list = append(list, crawl.handlers[pattern]...)
+				for i, h := range crawl.handlers[pattern] {
+					list = append(list, callbackHandler{callback: name, handler: h, index: i})
+				}
 				break
 			}
 		}
 	}
 	for _, name := range callbacks {
-		list = append(list, crawl.handlers[name]...)
+		for i, h := range crawl.handlers[name] {
+			list = append(list, callbackHandler{callback: name, handler: h, index: i})
+		}
 	}
 	return
 }
@@ -255,15 +832,48 @@ func (crawl *crawl) Middleware(m Middleware) {
 	crawl.middlewares = append(crawl.middlewares, m)
 }
 
-func (crawl *crawl) Register(name string, h Handler) {
+func (crawl *crawl) ResponseMiddleware(m ResponseMiddleware) {
+	crawl.responseMiddlewares = append(crawl.responseMiddlewares, m)
+}
+
+func (crawl *crawl) Register(name string, h Handler, opts ...HandlerOption) {
 	if _, ok := crawl.handlers[name]; !ok && strings.Contains(name, "*") {
 		crawl.patterns = append(crawl.patterns, name)
 	}
-	crawl.handlers[name] = append(crawl.handlers[name], h)
+	crawl.handlers[name] = append(crawl.handlers[name], routeHandler(h, opts...))
 }
 
 func (crawl *crawl) Schedule(ctx context.Context, req *Request) error {
-	return crawl.queue.Schedule(ctx, req)
+	// Trace and depth are set on a copy, caller can reuse the request
+	if trace, ok := TraceFromContext(ctx); ok && req.Trace == nil {
+		r := *req
+		r.Trace = trace
+		req = &r
+	}
+	if resp, ok := ResponseFromContext(ctx); ok && req.Depth == 0 && req.Referer != "" {
+		if req.Referer == resp.URL().String() || req.Referer == resp.Request.URL {
+			r := *req
+			r.Depth = resp.Request.Depth + 1
+			req = &r
+		}
+	}
+	if crawl.opts.maxDepth > 0 && req.Depth > crawl.opts.maxDepth {
+		return nil
+	}
+	if crawl.dedup != nil && !req.AllowDuplicate {
+		seen, err := crawl.dedup.Seen(req)
+		if err != nil {
+			return err
+		}
+		if seen {
+			return nil
+		}
+	}
+	if err := crawl.queue.Schedule(ctx, req); err != nil {
+		return &QueueError{ErrorInfo: newErrorInfo(req, time.Time{}), Op: "schedule", Err: err}
+	}
+	crawl.stats.add(func(stats *Stats) { stats.Scheduled++ })
+	return nil
 }
 
 func (crawl *crawl) Close() error {
@@ -273,6 +883,34 @@ func (crawl *crawl) Close() error {
 	return crawl.queue.Close()
 }
 
+// itemPipeline - Returns items pipeline, creates it if empty.
+func (crawl *crawl) itemPipeline() *pipeline {
+	if crawl.pipeline == nil {
+		crawl.pipeline = &pipeline{mutex: new(sync.Mutex)}
+	}
+	return crawl.pipeline
+}
+
+// flushItems - Flushes items exporters.
+// Errors are sent to errors channel.
+func (crawl *crawl) flushItems() {
+	if crawl.pipeline == nil {
+		return
+	}
+	for _, err := range crawl.pipeline.flush() {
+		crawl.sendError(err)
+	}
+}
+
+func (crawl *crawl) Stats() *Stats {
+	stats := crawl.stats.snapshot()
+	stats.QueueDepth = -1
+	if queue, ok := crawl.queue.(LenQueue); ok {
+		stats.QueueDepth = queue.Len()
+	}
+	return stats
+}
+
 func (crawl *crawl) Errors() <-chan error {
 	return crawl.errorsChan
 }
@@ -281,11 +919,35 @@ func (crawl *crawl) Handlers() map[string][]Handler {
 	return crawl.handlers
 }
 
-func (crawl *crawl) defaultTransport(dialer func(network, addr string) (net.Conn, error)) *http.Transport {
+// maxBodySize - Returns request maximum body size, zero means no limit.
+func (crawl *crawl) maxBodySize(req *Request) int64 {
+	if req.MaxBodySize > 0 {
+		return req.MaxBodySize
+	}
+	return crawl.opts.maxBodySize
+}
+
+// requestTimeout - Returns request total timeout.
+func (crawl *crawl) requestTimeout(req *Request) time.Duration {
+	if req.Timeout > 0 {
+		return req.Timeout
+	}
+	return orDefault(crawl.opts.totalTimeout, crawl.opts.defaultTimeout)
+}
+
+// dialer - Returns dialer with connect timeout.
+func (crawl *crawl) dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   orDefault(crawl.opts.connectTimeout, crawl.opts.defaultTimeout),
+		KeepAlive: crawl.opts.defaultTimeout,
+	}
+}
+
+func (crawl *crawl) defaultTransport(dialer dialFunc) *http.Transport {
 	return &http.Transport{
-		Dial:                  dialer,
-		TLSHandshakeTimeout:   crawl.opts.defaultTimeout,
-		ResponseHeaderTimeout: crawl.opts.defaultTimeout,
+		DialContext:           dialer,
+		TLSHandshakeTimeout:   orDefault(crawl.opts.tlsTimeout, crawl.opts.defaultTimeout),
+		ResponseHeaderTimeout: crawl.opts.firstByteTimeout,
 		ExpectContinueTimeout: time.Second,
 		// Connection pooling
 		MaxIdleConns:    100,
@@ -294,3 +956,11 @@ func (crawl *crawl) defaultTransport(dialer func(network, addr string) (net.Conn
 		Proxy: http.ProxyFromEnvironment,
 	}
 }
+
+// orDefault - Returns d if it is set, otherwise default duration.
+func orDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
@@ -14,7 +14,28 @@ type options struct {
 	queueCapacity int
 	headers       map[string]string
 
-	defaultTimeout time.Duration
+	defaultTimeout   time.Duration
+	connectTimeout   time.Duration
+	tlsTimeout       time.Duration
+	firstByteTimeout time.Duration
+	totalTimeout     time.Duration
+
+	hostConcurrency int
+	hostDelay       time.Duration
+	hostJitter      time.Duration
+	hostQueueLimit  int
+
+	robotsAgent string
+
+	maxDepth int
+
+	offline bool
+
+	errorCallback string
+
+	maxBodySize int64
+
+	proxies []string
 }
 
 // WithTransport - Sets crawl HTTP transport.
@@ -78,8 +99,243 @@ func WithSpiders(spiders ...func(Crawler)) Option {
 }
 
 // WithDefaultTimeout - Sets default request timeout duration.
+// It is used as total, connect and TLS handshake timeout
+// if they are not set. Request Timeout overwrites total timeout.
 func WithDefaultTimeout(d time.Duration) Option {
 	return func(c *crawl) {
 		c.opts.defaultTimeout = d
 	}
 }
+
+// WithConnectTimeout - Sets timeout of establishing a connection.
+// Default: default timeout.
+func WithConnectTimeout(d time.Duration) Option {
+	return func(c *crawl) {
+		c.opts.connectTimeout = d
+	}
+}
+
+// WithTLSHandshakeTimeout - Sets timeout of TLS handshake.
+// Default: default timeout.
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+	return func(c *crawl) {
+		c.opts.tlsTimeout = d
+	}
+}
+
+// WithFirstByteTimeout - Sets timeout of waiting for response headers
+// after request is written. It is not overwritten by request Timeout.
+// Default: 0 (limited only by total timeout).
+func WithFirstByteTimeout(d time.Duration) Option {
+	return func(c *crawl) {
+		c.opts.firstByteTimeout = d
+	}
+}
+
+// WithTotalTimeout - Sets timeout of a request including reading
+// response body, it does not include handlers execution.
+// Request Timeout overwrites it.
+// Default: default timeout.
+func WithTotalTimeout(d time.Duration) Option {
+	return func(c *crawl) {
+		c.opts.totalTimeout = d
+	}
+}
+
+// WithHostConcurrency - Sets maximum number of in-flight requests per host.
+// Jobs for a host which reached the limit wait in memory
+// without blocking workers that could serve other hosts.
+// Number of waiting jobs is limited using WithHostQueueLimit.
+// Default: 0 (no limit).
+func WithHostConcurrency(n int) Option {
+	return func(c *crawl) {
+		c.opts.hostConcurrency = n
+	}
+}
+
+// WithHostDelay - Sets minimum delay between requests to the same host.
+// Default: 0 (no delay).
+func WithHostDelay(d time.Duration) Option {
+	return func(c *crawl) {
+		c.opts.hostDelay = d
+	}
+}
+
+// WithHostJitter - Sets maximum random duration added to host delay.
+// Default: 0 (no jitter).
+func WithHostJitter(d time.Duration) Option {
+	return func(c *crawl) {
+		c.opts.hostJitter = d
+	}
+}
+
+// WithHostQueueLimit - Sets maximum number of jobs read from the queue
+// and waiting in memory for a throttled host. Reading from the queue
+// is blocked when a host reaches the limit so remaining jobs stay
+// in the queue and keep their priority. Number of jobs waiting for
+// all hosts is limited by crawler concurrency.
+// Default: 1.
+func WithHostQueueLimit(n int) Option {
+	return func(c *crawl) {
+		c.opts.hostQueueLimit = n
+	}
+}
+
+// WithRobots - Enables robots.txt enforcement for user-agent.
+// Robots.txt is fetched once per host through a proxy selected
+// for the first request to the host, same as for page requests.
+// Disallowed requests fail with *RobotsError and Crawl-delay
+// is used as a minimum delay between requests to the host.
+func WithRobots(userAgent string) Option {
+	return func(c *crawl) {
+		c.opts.robotsAgent = userAgent
+	}
+}
+
+// WithDeduplicator - Sets requests deduplicator consulted on Schedule.
+// Requests with AllowDuplicate set are always scheduled.
+// Default: none.
+func WithDeduplicator(dedup Deduplicator) Option {
+	return func(c *crawl) {
+		c.dedup = dedup
+	}
+}
+
+// WithRetryPolicy - Sets failed requests retry policy.
+// Failed requests are scheduled again in the queue before failed job
+// is marked as done, retry is not lost when crawler stops during
+// the backoff if the queue is persistent. Errors are sent
+// to Errors() channel only after the final attempt. When set, responses
+// with 5xx and 429 status codes are treated as errors unless it is the
+// final attempt, then response is routed to error callback.
+// Default: none.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(c *crawl) {
+		c.retry = policy
+	}
+}
+
+// WithMaxDepth - Sets maximum crawl depth.
+// Requests with greater depth are not scheduled.
+// Default: 0 (no limit).
+func WithMaxDepth(n int) Option {
+	return func(c *crawl) {
+		c.opts.maxDepth = n
+	}
+}
+
+// WithCache - Sets http responses cache.
+// Responses are stored by request fingerprint and revalidated
+// using ETag and Last-Modified headers.
+// Default: none.
+func WithCache(cache Cache) Option {
+	return func(c *crawl) {
+		c.cache = cache
+	}
+}
+
+// WithOffline - Enables offline mode in which responses are served only
+// from cache set using WithCache. Requests not found in cache fail
+// with ErrCacheMiss and all requests fail with ErrNoCache if cache is not set.
+func WithOffline() Option {
+	return func(c *crawl) {
+		c.opts.offline = true
+	}
+}
+
+// WithErrorCallback - Sets default callback executed instead of request
+// callbacks for responses with 4xx and 5xx status code.
+// Request OnError callback takes precedence.
+// Default: none.
+func WithErrorCallback(name string) Option {
+	return func(c *crawl) {
+		c.opts.errorCallback = name
+	}
+}
+
+// WithMaxBodySize - Sets maximum response body size in bytes.
+// Reading larger body fails with *BodySizeError.
+// It can be overwritten by request MaxBodySize.
+// Default: 0 (no limit).
+func WithMaxBodySize(n int64) Option {
+	return func(c *crawl) {
+		c.opts.maxBodySize = n
+	}
+}
+
+// WithProcessors - Registers items processors.
+// Processors are executed in order on every emitted item.
+func WithProcessors(processors ...Processor) Option {
+	return func(c *crawl) {
+		p := c.itemPipeline()
+		p.processors = append(p.processors, processors...)
+	}
+}
+
+// WithExporters - Registers items exporters.
+// Exporters are flushed when crawler is stopped.
+func WithExporters(exporters ...Exporter) Option {
+	return func(c *crawl) {
+		p := c.itemPipeline()
+		p.exporters = append(p.exporters, exporters...)
+	}
+}
+
+// WithFollow - Registers handler on callback which follows links
+// extracted from every response using rules.
+func WithFollow(callback string, rules ...FollowRule) Option {
+	return func(c *crawl) {
+		c.Register(callback, Follow(c, rules...))
+	}
+}
+
+// WithProxies - Sets proxies requests are executed through.
+// Proxy is selected using proxy pool strategy, request Proxy
+// and context proxies take precedence.
+// Default: none.
+func WithProxies(addrs ...string) Option {
+	return func(c *crawl) {
+		c.opts.proxies = addrs
+	}
+}
+
+// WithProxyPool - Sets proxy pool used for all requests.
+// Request Proxy, context proxies and proxies set using WithProxies
+// are used instead if present, health and bans of those are tracked
+// by the pool too. Bans are detected only when pool is set.
+// Proxies transports are created by the pool, crawler timeouts
+// are not applied to them, see WithProxyTransport.
+// Default: empty pool selecting randomly from other proxies.
+func WithProxyPool(pool *ProxyPool) Option {
+	return func(c *crawl) {
+		c.proxies = pool
+	}
+}
+
+// WithLogger - Sets crawler logger.
+// Default: NopLogger.
+func WithLogger(logger Logger) Option {
+	return func(c *crawl) {
+		c.logger = logger
+	}
+}
+
+// WithSpanExporter - Enables tracing and sets spans exporter.
+// Spans are created for requests, DNS lookups, connects, TLS handshakes,
+// first response bytes and handlers.
+// Default: none (tracing disabled).
+func WithSpanExporter(exporter SpanExporter) Option {
+	return func(c *crawl) {
+		c.spans = exporter
+	}
+}
+
+// WithPanicHandler - Sets function called when a job panics.
+// Panics of handlers and middlewares are recovered per job and sent
+// to errors channel as *PanicError wrapped in *RequestError.
+// Default: none.
+func WithPanicHandler(fn PanicHandler) Option {
+	return func(c *crawl) {
+		c.panicHandler = fn
+	}
+}
@@ -0,0 +1,361 @@
+package crawl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// TestStartIdle -
+func TestStartIdle(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><body>%s</body></html>", r.URL.Path)
+	}))
+	defer ts.Close()
+
+	c := New(WithConcurrency(2))
+	pages := make(chan string, 10)
+	c.Register("page", func(ctx context.Context, resp *Response) error {
+		pages <- Text(resp, "body")
+		return nil
+	})
+	c.Register("index", func(ctx context.Context, resp *Response) error {
+		for i := 0; i < 2; i++ {
+			c.Schedule(ctx, &Request{URL: fmt.Sprintf("/%d", i), Referer: ts.URL, Callbacks: Callbacks("page")})
+		}
+		return nil
+	})
+	c.Schedule(context.Background(), &Request{URL: ts.URL, Callbacks: Callbacks("index")})
+
+	done := make(chan error, 1)
+	go func() { done <- c.StartContext(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("crawler did not finish when idle")
+	}
+	if len(pages) != 2 {
+		t.Errorf("expected 2 pages, got %d", len(pages))
+	}
+	if _, ok := <-c.Errors(); ok {
+		t.Error("expected errors channel to be closed")
+	}
+}
+
+// TestShutdown -
+func TestShutdown(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	c := New(WithConcurrency(1))
+	started := make(chan bool)
+	c.Register("slow", func(ctx context.Context, resp *Response) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		// Handler can schedule until the queue is closed
+		return c.Schedule(ctx, &Request{URL: ts.URL})
+	})
+	c.Schedule(context.Background(), &Request{URL: ts.URL, Callbacks: Callbacks("slow")})
+	go c.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for err := range c.Errors() {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestScheduleDepth -
+func TestScheduleDepth(t *testing.T) {
+	queue := NewPriorityQueue(10)
+	c := New(WithQueue(queue), WithMaxDepth(2))
+	httpReq, _ := http.NewRequest("GET", "http://example.com/list", nil)
+	ctx := WithResponse(WithTrace(context.Background(), NewTraceContext()), &Response{
+		Request:  &Request{URL: "http://example.com/list", Depth: 1},
+		Response: &http.Response{Request: httpReq},
+	})
+	req := &Request{URL: "/a", Referer: "http://example.com/list"}
+	c.Schedule(ctx, req)
+	if req.Depth != 0 || req.Trace != nil {
+		t.Error("expected scheduled request not to be modified")
+	}
+	c.Schedule(ctx, &Request{URL: "/b", Referer: "http://example.com/other"})
+	c.Schedule(ctx, &Request{URL: "/c", Referer: "http://example.com/list", Depth: 3})
+	queue.Close()
+	for _, expected := range []int{2, 0} {
+		job, err := queue.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Request().Depth != expected {
+			t.Errorf("%s: expected depth %d, got %d", job.Request().URL, expected, job.Request().Depth)
+		}
+	}
+	if _, err := queue.Get(); err == nil {
+		t.Error("expected request exceeding max depth to be dropped")
+	}
+}
+
+// TestResponseMiddleware -
+func TestResponseMiddleware(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	c := New()
+	c.ResponseMiddleware(func(_ context.Context, resp *Response) error {
+		if resp.StatusCode != http.StatusOK {
+			return ErrSkipHandlers
+		}
+		return nil
+	})
+	c.Register("page", func(context.Context, *Response) error {
+		t.Error("handler should be skipped")
+		return nil
+	})
+	if _, err := c.Execute(context.Background(), &Request{URL: ts.URL, Callbacks: Callbacks("page")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestErrorCallback -
+func TestErrorCallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	var called []string
+	c := New(WithErrorCallback("default_error"))
+	for _, name := range []string{"page", "page_error", "default_error"} {
+		name := name
+		c.Register(name, func(context.Context, *Response) error {
+			called = append(called, name)
+			return nil
+		})
+	}
+	c.Register("success", func(context.Context, *Response) error {
+		t.Error("success handler should be skipped")
+		return nil
+	}, OnSuccess())
+	c.Execute(context.Background(), &Request{URL: ts.URL, Callbacks: Callbacks("page"), OnError: "page_error"})
+	c.Execute(context.Background(), &Request{URL: ts.URL, Callbacks: Callbacks("page")})
+	c.Execute(context.Background(), &Request{URL: ts.URL, Callbacks: Callbacks("success")})
+	if len(called) != 3 || called[0] != "page_error" || called[1] != "default_error" {
+		t.Errorf("unexpected callbacks %v", called)
+	}
+
+	// Without error callbacks response reaches status filtered handlers
+	called = nil
+	c = New()
+	c.Register("page", func(context.Context, *Response) error {
+		t.Error("success handler should be skipped")
+		return nil
+	}, OnSuccess())
+	c.Register("page", func(context.Context, *Response) error {
+		called = append(called, "not_found")
+		return nil
+	}, OnStatus(404, 404))
+	if _, err := c.Execute(context.Background(), &Request{URL: ts.URL, Callbacks: Callbacks("page")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(called) != 1 {
+		t.Errorf("expected status handler to be called, got %v", called)
+	}
+}
+
+// TestErrorCallbackRetry -
+func TestErrorCallbackRetry(t *testing.T) {
+	var attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	// Final attempt is routed to error callback instead of Errors()
+	c := New(WithRetryPolicy(&RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond}))
+	var status int
+	c.Register("page_error", func(_ context.Context, resp *Response) error {
+		status = resp.StatusCode
+		return nil
+	})
+	c.Schedule(context.Background(), &Request{URL: ts.URL, OnError: "page_error"})
+	if err := c.StartContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for err := range c.Errors() {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if attempts != 2 || status != http.StatusServiceUnavailable {
+		t.Errorf("expected error callback after 2 attempts, got %d attempts and status %d", attempts, status)
+	}
+}
+
+// recordQueue - Queue recording scheduled requests.
+type recordQueue struct {
+	Queue
+	mutex    *sync.Mutex
+	requests []*Request
+}
+
+func (queue *recordQueue) Schedule(ctx context.Context, req *Request) error {
+	queue.mutex.Lock()
+	queue.requests = append(queue.requests, req)
+	queue.mutex.Unlock()
+	return queue.Queue.Schedule(ctx, req)
+}
+
+// TestShutdownRetryBackoff -
+func TestShutdownRetryBackoff(t *testing.T) {
+	hits := make(chan bool, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits <- true
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	queue := &recordQueue{Queue: NewQueue(10), mutex: new(sync.Mutex)}
+	c := New(WithQueue(queue), WithRetryPolicy(&RetryPolicy{MaxAttempts: 2, MinBackoff: 400 * time.Millisecond}))
+	c.Schedule(context.Background(), &Request{URL: ts.URL})
+	go c.Start()
+	<-hits
+
+	// Retry is scheduled before failed job is done and it stays
+	// in the queue unacknowledged when crawler stops during backoff
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for err := range c.Errors() {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(hits) != 0 {
+		t.Error("expected retry not to be executed")
+	}
+	if len(queue.requests) != 2 || queue.requests[1].Attempt != 1 || queue.requests[1].NotBefore == nil {
+		t.Fatalf("expected retry to be scheduled, got %v", queue.requests)
+	}
+	if queue.Queue.(IdleQueue).Idle() {
+		t.Error("expected retry not to be done")
+	}
+	if stats := c.Stats(); stats.Retried != 1 || stats.Failed != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+// TestRequestTimeout -
+func TestRequestTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	c := New(WithDefaultTimeout(20 * time.Millisecond))
+	if _, err := c.Execute(context.Background(), &Request{URL: ts.URL}); err == nil {
+		t.Error("expected timeout error")
+	}
+
+	var deadline bool
+	c.Register("slow", func(ctx context.Context, _ *Response) error {
+		_, deadline = ctx.Deadline()
+		return nil
+	})
+	if _, err := c.Execute(context.Background(), &Request{URL: ts.URL, Timeout: time.Second, Callbacks: Callbacks("slow")}); err != nil {
+		t.Fatal(err)
+	}
+	if deadline {
+		t.Error("request timeout should not be applied to handlers context")
+	}
+}
+
+// TestRequestJSON -
+func TestRequestJSON(t *testing.T) {
+	req := &Request{URL: "http://example.com/", Timeout: 90 * time.Second, Attempt: 1}
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"url":"http://example.com/","attempt":1,"timeout":"1m30s"}`; string(body) != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+	decoded := new(Request)
+	if err := json.Unmarshal(body, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, req) {
+		t.Errorf("expected %+v, got %+v", req, decoded)
+	}
+
+	// Integer nanoseconds are accepted
+	if err := json.Unmarshal([]byte(`{"url":"http://example.com/","timeout":30000000000}`), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Timeout != 30*time.Second {
+		t.Errorf("unexpected timeout %v", decoded.Timeout)
+	}
+	if err := json.Unmarshal([]byte(`{"timeout":"soon"}`), decoded); err == nil {
+		t.Error("expected invalid timeout error")
+	}
+}
+
+// TestPanicRecovery -
+func TestPanicRecovery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><body>%s</body></html>", r.URL.Path)
+	}))
+	defer ts.Close()
+
+	var handled *PanicError
+	c := New(
+		WithConcurrency(1),
+		WithRetryPolicy(DefaultRetryPolicy),
+		WithPanicHandler(func(_ context.Context, err *PanicError) { handled = err }),
+	)
+	c.Register("page", func(ctx context.Context, resp *Response) error {
+		if Text(resp, "body") == "/panic" {
+			panic("malformed page")
+		}
+		return nil
+	})
+	c.Schedule(context.Background(), &Request{URL: ts.URL + "/panic", Callbacks: Callbacks("page")})
+	c.Schedule(context.Background(), &Request{URL: ts.URL + "/ok", Callbacks: Callbacks("page")})
+	if err := c.StartContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	err := <-c.Errors()
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+	if panicErr.Value != "malformed page" || len(panicErr.Stack) == 0 || panicErr.Request.URL != ts.URL+"/panic" {
+		t.Errorf("unexpected panic error %+v", panicErr)
+	}
+	if handled != panicErr {
+		t.Error("expected panic handler to be called")
+	}
+	for err := range c.Errors() {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if stats := c.Stats(); stats.Panics != 1 || stats.Completed != 1 || stats.Failed != 1 || stats.Retried != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,108 @@
+package crawl
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// Deduplicator - Requests deduplicator consulted on Schedule.
+// Requests that were already seen are not scheduled.
+type Deduplicator interface {
+	// Seen - Checks if request was already seen and marks it as seen.
+	Seen(*Request) (bool, error)
+}
+
+// NewMemoryDeduplicator - Creates a deduplicator which keeps
+// all request fingerprints in memory.
+func NewMemoryDeduplicator() Deduplicator {
+	return &memDeduplicator{
+		seen:  make(map[string]struct{}),
+		mutex: new(sync.Mutex),
+	}
+}
+
+type memDeduplicator struct {
+	seen  map[string]struct{}
+	mutex *sync.Mutex
+}
+
+func (dedup *memDeduplicator) Seen(req *Request) (bool, error) {
+	fp, err := req.Fingerprint()
+	if err != nil {
+		return false, err
+	}
+	dedup.mutex.Lock()
+	defer dedup.mutex.Unlock()
+	if _, ok := dedup.seen[fp]; ok {
+		return true, nil
+	}
+	dedup.seen[fp] = struct{}{}
+	return false, nil
+}
+
+// NewBloomDeduplicator - Creates a deduplicator using bloom filter.
+// Filter is sized for n requests with false positive rate p.
+// False positive means a request that was never scheduled is
+// treated as a duplicate. It is suitable for very large crawls
+// where keeping all fingerprints in memory is not possible.
+// Returns an error if p is not in range (0, 1).
+func NewBloomDeduplicator(n uint, p float64) (Deduplicator, error) {
+	if !(p > 0 && p < 1) {
+		return nil, fmt.Errorf("bloom deduplicator: false positive rate %v not in range (0, 1)", p)
+	}
+	if n == 0 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m == 0 {
+		m = 1
+	}
+	k := uint64(math.Ceil(float64(m) / float64(n) * math.Ln2))
+	if k == 0 {
+		k = 1
+	}
+	return &bloomDeduplicator{
+		bits:  make([]uint64, (m+63)/64),
+		m:     m,
+		k:     k,
+		mutex: new(sync.Mutex),
+	}, nil
+}
+
+type bloomDeduplicator struct {
+	bits  []uint64
+	m, k  uint64
+	mutex *sync.Mutex
+}
+
+func (dedup *bloomDeduplicator) Seen(req *Request) (bool, error) {
+	fp, err := req.Fingerprint()
+	if err != nil {
+		return false, err
+	}
+	h1, h2 := bloomHashes(fp)
+	dedup.mutex.Lock()
+	defer dedup.mutex.Unlock()
+	seen := true
+	for i := uint64(0); i < dedup.k; i++ {
+		bit := (h1 + i*h2) % dedup.m
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if dedup.bits[word]&mask == 0 {
+			seen = false
+			dedup.bits[word] |= mask
+		}
+	}
+	return seen, nil
+}
+
+// bloomHashes - Returns two hashes of fingerprint for double hashing.
+func bloomHashes(fp string) (uint64, uint64) {
+	a := fnv.New64a()
+	a.Write([]byte(fp))
+	b := fnv.New64()
+	b.Write([]byte(fp))
+	// Second hash has to be odd so it does not cycle on even sizes
+	return a.Sum64(), b.Sum64() | 1
+}
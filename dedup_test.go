@@ -0,0 +1,49 @@
+package crawl
+
+import (
+	"math"
+	"net/url"
+	"testing"
+)
+
+// TestRequestFingerprint -
+func TestRequestFingerprint(t *testing.T) {
+	a, _ := (&Request{URL: "/page?b=2&a=1#top", Referer: "http://EXAMPLE.com/"}).Fingerprint()
+	b, _ := (&Request{URL: "http://example.com/page", Query: url.Values{"a": {"1"}, "b": {"2"}}}).Fingerprint()
+	if a != b {
+		t.Errorf("expected equal fingerprints")
+	}
+	c, _ := (&Request{URL: "http://example.com/page?a=1&b=2", Form: url.Values{"x": {"1"}}}).Fingerprint()
+	if a == c {
+		t.Errorf("expected different fingerprint for form request")
+	}
+}
+
+// TestDeduplicators -
+func TestDeduplicators(t *testing.T) {
+	bloom, err := NewBloomDeduplicator(1000, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dedup := range []Deduplicator{NewMemoryDeduplicator(), bloom} {
+		req := &Request{URL: "http://example.com/"}
+		if seen, _ := dedup.Seen(req); seen {
+			t.Errorf("%T: request seen on first call", dedup)
+		}
+		if seen, _ := dedup.Seen(req); !seen {
+			t.Errorf("%T: request not seen on second call", dedup)
+		}
+		if seen, _ := dedup.Seen(&Request{URL: "http://example.com/other"}); seen {
+			t.Errorf("%T: other request seen", dedup)
+		}
+	}
+}
+
+// TestBloomDeduplicatorRate -
+func TestBloomDeduplicatorRate(t *testing.T) {
+	for _, p := range []float64{0, -0.1, 1, 2, math.NaN()} {
+		if _, err := NewBloomDeduplicator(1000, p); err == nil {
+			t.Errorf("expected error for false positive rate %v", p)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+// Package diskqueue implements persistent crawl queue.
+//
+// Requests are appended to segment files in a directory. Every segment
+// has an acknowledgment file with offsets of requests which are done.
+// Requests which were not acknowledged are redelivered after a restart.
+// Segment is removed when all of its requests are acknowledged.
+//
+// Files are not synced by default, scheduled requests written to the
+// operating system survive a crash of the process but they can be lost
+// on power loss. WithSync makes every schedule durable.
+package diskqueue
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/crackcomm/crawl"
+	"github.com/crackcomm/crawl/envelope"
+)
+
+// DefaultSegmentSize - Size of segment file after which a new one is created.
+const DefaultSegmentSize int64 = 64 * 1024 * 1024
+
+// Request - Request as it is stored on disk.
+type Request = envelope.Request
+
+// Option - Queue option.
+type Option func(*Queue)
+
+// WithSegmentSize - Sets size of segment file after which a new one is created.
+// Default: DefaultSegmentSize.
+func WithSegmentSize(size int64) Option {
+	return func(queue *Queue) {
+		queue.segmentSize = size
+	}
+}
+
+// WithSync - Syncs segment file to disk before Schedule returns.
+// Acknowledgments are not synced, lost ones cause redelivery.
+// Default: disabled.
+func WithSync() Option {
+	return func(queue *Queue) {
+		queue.sync = true
+	}
+}
+
+// WithLogger - Sets queue logger.
+// Default: crawl.NopLogger.
+func WithLogger(logger crawl.Logger) Option {
+	return func(queue *Queue) {
+		queue.logger = logger
+	}
+}
+
+// Queue - Disk queue.
+type Queue struct {
+	dir         string
+	segmentSize int64
+	sync        bool
+	logger      crawl.Logger
+
+	mutex  *sync.Mutex
+	cond   *sync.Cond
+	closed bool
+
+	// segments - Segments ordered by id, last one is written.
+	segments []*segment
+	// reader - Segment which is currently read.
+	reader *segment
+	// inFlight - Number of read requests which are not acknowledged.
+	inFlight int
+}
+
+// segment - Segment file with its acknowledgments.
+type segment struct {
+	id int64
+
+	file *os.File
+	ack  *os.File
+	size int64
+
+	// read - Reader of segment file and offset of next record.
+	reader *os.File
+	read   *bufio.Reader
+	offset int64
+
+	// pushed - Number of records in segment.
+	pushed int
+	// readCount - Number of records read from segment.
+	readCount int
+	// inFlight - Number of read records which are not acknowledged.
+	inFlight int
+	// replayed - Offsets acknowledged before restart.
+	// It is released after segment is read.
+	replayed map[int64]bool
+}
+
+// NewQueue - Opens or creates disk queue in a directory.
+func NewQueue(dir string, opts ...Option) (queue *Queue, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	queue = &Queue{
+		dir:         dir,
+		segmentSize: DefaultSegmentSize,
+		logger:      crawl.NopLogger,
+		mutex:       new(sync.Mutex),
+	}
+	for _, opt := range opts {
+		opt(queue)
+	}
+	queue.cond = sync.NewCond(queue.mutex)
+	if err = queue.open(); err != nil {
+		queue.closeFiles()
+		return nil, err
+	}
+	return
+}
+
+// Schedule - Appends request to the queue.
+// Returns io.ErrClosedPipe if queue is closed.
+func (queue *Queue) Schedule(ctx context.Context, req *crawl.Request) (err error) {
+	body, err := json.Marshal(envelope.New(ctx, req))
+	if err != nil {
+		return
+	}
+	body = append(body, '\n')
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.closed {
+		return io.ErrClosedPipe
+	}
+	seg := queue.segments[len(queue.segments)-1]
+	if seg.size >= queue.segmentSize {
+		if seg, err = queue.createSegment(seg.id + 1); err != nil {
+			return
+		}
+	}
+	if _, err = seg.file.Write(body); err == nil && queue.sync {
+		err = seg.file.Sync()
+	}
+	if err != nil {
+		queue.rollback(seg, err)
+		return
+	}
+	seg.size += int64(len(body))
+	seg.pushed++
+	queue.cond.Signal()
+	return
+}
+
+// Get - Gets next request from the queue.
+// It blocks until request is available.
+// Returns io.EOF if queue is closed.
+func (queue *Queue) Get() (crawl.Job, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for {
+		if queue.closed {
+			return nil, io.EOF
+		}
+		seg := queue.reader
+		if seg.offset < seg.size {
+			job, err := queue.readJob(seg)
+			if err != nil {
+				return nil, err
+			}
+			if job != nil {
+				return job, nil
+			}
+			continue
+		}
+		if next := queue.nextSegment(seg); next != nil {
+			queue.reader = next
+			seg.replayed = nil
+			queue.removeDone(seg)
+			continue
+		}
+		queue.cond.Wait()
+	}
+}
+
+// Idle - Returns true if all requests were read and acknowledged.
+func (queue *Queue) Idle() bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.inFlight > 0 {
+		return false
+	}
+	for _, seg := range queue.segments {
+		if seg.offset < seg.size {
+			return false
+		}
+	}
+	return true
+}
+
+// Len - Returns number of requests which were not read yet.
+func (queue *Queue) Len() (n int) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for _, seg := range queue.segments {
+		n += seg.pushed - seg.readCount
+	}
+	return
+}
+
+// Close - Closes the queue files.
+func (queue *Queue) Close() error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.closed {
+		return nil
+	}
+	queue.closed = true
+	queue.cond.Broadcast()
+	return queue.closeFiles()
+}
+
+// readJob - Reads next record from segment.
+// Returns nil job if record was acknowledged, its deadline exceeded
+// or it is corrupted. Mutex has to be locked.
+func (queue *Queue) readJob(seg *segment) (_ crawl.Job, err error) {
+	line, err := seg.read.ReadBytes('\n')
+	if err != nil {
+		return nil, queue.skipSegment(seg, err)
+	}
+	offset := seg.offset
+	seg.offset += int64(len(line))
+	seg.readCount++
+	// Number of records can be lower than pushed if segment was corrupted
+	if seg.offset >= seg.size {
+		seg.readCount = seg.pushed
+	}
+	if seg.replayed[offset] {
+		return
+	}
+
+	req := new(Request)
+	if e := json.Unmarshal(line, req); e != nil || req.Request == nil {
+		// Invalid records are given up
+		queue.logger.Log(crawl.LevelWarning, "diskqueue invalid record", crawl.F("segment", seg.id), crawl.F("offset", offset), crawl.F("error", e))
+		queue.ack(seg, offset)
+		return
+	}
+
+	// Check if deadline exceeded
+	if req.Expired() {
+		queue.ack(seg, offset)
+		return
+	}
+
+	ctx := req.Context(context.Background())
+	seg.inFlight++
+	queue.inFlight++
+	return &diskJob{queue: queue, seg: seg, offset: offset, req: req.Request, ctx: ctx}, nil
+}
+
+// skipSegment - Gives up remaining records of a segment which could not
+// be read, eg. when a record separator was corrupted. Writing continues
+// in a new segment so new records are not appended to a corrupted one.
+// Mutex has to be locked.
+func (queue *Queue) skipSegment(seg *segment, readErr error) (err error) {
+	lost := seg.pushed - seg.readCount
+	queue.logger.Log(crawl.LevelError, "diskqueue segment corrupted", crawl.F("segment", seg.id), crawl.F("lost", lost), crawl.F("error", readErr))
+	seg.offset = seg.size
+	seg.readCount = seg.pushed
+	if seg == queue.segments[len(queue.segments)-1] {
+		_, err = queue.createSegment(seg.id + 1)
+	}
+	return
+}
+
+// rollback - Removes partially written record from the end of segment
+// so next records are not appended after it. If it fails writing
+// continues in a new segment, incomplete record is truncated on open.
+// Mutex has to be locked.
+func (queue *Queue) rollback(seg *segment, writeErr error) {
+	err := seg.file.Truncate(seg.size)
+	if err == nil {
+		_, err = seg.file.Seek(seg.size, io.SeekStart)
+	}
+	if err == nil {
+		return
+	}
+	queue.logger.Log(crawl.LevelError, "diskqueue write error", crawl.F("segment", seg.id), crawl.F("error", writeErr), crawl.F("rollback_error", err))
+	if _, err = queue.createSegment(seg.id + 1); err != nil {
+		queue.logger.Log(crawl.LevelError, "diskqueue segment error", crawl.F("segment", seg.id+1), crawl.F("error", err))
+	}
+}
+
+// ack - Writes acknowledgment of a record.
+// Mutex has to be locked.
+func (queue *Queue) ack(seg *segment, offset int64) {
+	if queue.closed || seg.ack == nil {
+		return
+	}
+	// Error is ignored, record will be redelivered after restart
+	fmt.Fprintf(seg.ack, "%d\n", offset)
+	queue.removeDone(seg)
+}
+
+// removeDone - Removes segment if all records were read and acknowledged.
+// Segments before the read one were read entirely.
+// Written segment is never removed.
+// Mutex has to be locked.
+func (queue *Queue) removeDone(seg *segment) {
+	last := queue.segments[len(queue.segments)-1]
+	if seg == last || seg == queue.reader || seg.inFlight > 0 {
+		return
+	}
+	seg.file.Close()
+	seg.ack.Close()
+	seg.reader.Close()
+	seg.ack = nil
+	os.Remove(queue.segmentPath(seg.id, "seg"))
+	os.Remove(queue.segmentPath(seg.id, "ack"))
+	for i, s := range queue.segments {
+		if s == seg {
+			queue.segments = append(queue.segments[:i], queue.segments[i+1:]...)
+			break
+		}
+	}
+}
+
+// nextSegment - Returns segment after seg or nil.
+// Mutex has to be locked.
+func (queue *Queue) nextSegment(seg *segment) *segment {
+	for i, s := range queue.segments {
+		if s == seg && i+1 < len(queue.segments) {
+			return queue.segments[i+1]
+		}
+	}
+	return nil
+}
+
+// open - Opens existing segments or creates first one.
+func (queue *Queue) open() (err error) {
+	names, err := filepath.Glob(filepath.Join(queue.dir, "*.seg"))
+	if err != nil {
+		return
+	}
+	var ids []int64
+	for _, name := range names {
+		id, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), ".seg"), 10, 64)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if err = queue.openSegment(id); err != nil {
+			return
+		}
+	}
+	if len(queue.segments) == 0 {
+		if _, err = queue.createSegment(0); err != nil {
+			return
+		}
+	}
+	queue.reader = queue.segments[0]
+	return
+}
+
+// openSegment - Opens existing segment and replays acknowledgments.
+// Incomplete record at the end of a segment is truncated.
+func (queue *Queue) openSegment(id int64) (err error) {
+	seg := &segment{id: id, replayed: make(map[int64]bool)}
+	if seg.file, err = os.OpenFile(queue.segmentPath(id, "seg"), os.O_RDWR, 0644); err != nil {
+		return
+	}
+	queue.segments = append(queue.segments, seg)
+
+	// Count complete records
+	r := bufio.NewReader(seg.file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		seg.size += int64(len(line))
+		seg.pushed++
+	}
+	if err = seg.file.Truncate(seg.size); err != nil {
+		return
+	}
+	if _, err = seg.file.Seek(seg.size, io.SeekStart); err != nil {
+		return
+	}
+
+	// Replay acknowledgments
+	if seg.ack, err = os.OpenFile(queue.segmentPath(id, "ack"), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return
+	}
+	scanner := bufio.NewScanner(seg.ack)
+	for scanner.Scan() {
+		if offset, err := strconv.ParseInt(scanner.Text(), 10, 64); err == nil {
+			seg.replayed[offset] = true
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	return queue.openReader(seg)
+}
+
+// createSegment - Creates a new segment which is written.
+func (queue *Queue) createSegment(id int64) (seg *segment, err error) {
+	seg = &segment{id: id}
+	if seg.file, err = os.OpenFile(queue.segmentPath(id, "seg"), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644); err != nil {
+		return
+	}
+	queue.segments = append(queue.segments, seg)
+	if seg.ack, err = os.OpenFile(queue.segmentPath(id, "ack"), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return
+	}
+	if queue.sync {
+		if err = syncDir(queue.dir); err != nil {
+			return
+		}
+	}
+	err = queue.openReader(seg)
+	return
+}
+
+// syncDir - Syncs directory so created files survive power loss.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// openReader - Opens segment file for reading from the beginning.
+func (queue *Queue) openReader(seg *segment) error {
+	f, err := os.Open(queue.segmentPath(seg.id, "seg"))
+	if err != nil {
+		return err
+	}
+	seg.reader = f
+	seg.read = bufio.NewReader(f)
+	return nil
+}
+
+// closeFiles - Closes all segment files.
+func (queue *Queue) closeFiles() (err error) {
+	for _, seg := range queue.segments {
+		for _, f := range []*os.File{seg.file, seg.ack, seg.reader} {
+			if f == nil {
+				continue
+			}
+			if e := f.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return
+}
+
+func (queue *Queue) segmentPath(id int64, ext string) string {
+	return filepath.Join(queue.dir, fmt.Sprintf("%020d.%s", id, ext))
+}
+
+type diskJob struct {
+	queue  *Queue
+	seg    *segment
+	offset int64
+	req    *crawl.Request
+	ctx    context.Context
+	done   bool
+}
+
+func (job *diskJob) Context() context.Context { return job.ctx }
+func (job *diskJob) Request() *crawl.Request  { return job.req }
+
+// Done - Acknowledges the job.
+func (job *diskJob) Done() {
+	job.queue.mutex.Lock()
+	defer job.queue.mutex.Unlock()
+	if !job.done {
+		job.done = true
+		job.seg.inFlight--
+		job.queue.inFlight--
+		job.queue.ack(job.seg, job.offset)
+	}
+}
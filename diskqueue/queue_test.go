@@ -0,0 +1,187 @@
+package diskqueue
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/crackcomm/crawl"
+)
+
+// TestQueueRedelivery -
+func TestQueueRedelivery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Small segments to test rotation and removal
+	queue, err := NewQueue(dir, WithSegmentSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := queue.Schedule(context.Background(), &crawl.Request{URL: fmt.Sprintf("http://example.com/%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		job, err := queue.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Only first job is acknowledged
+		if i == 0 {
+			job.Done()
+		}
+	}
+	if err := queue.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err = NewQueue(dir, WithSegmentSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+	for i := 1; i < 4; i++ {
+		job, err := queue.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("http://example.com/%d", i); job.Request().URL != expected {
+			t.Fatalf("expected %s, got %s", expected, job.Request().URL)
+		}
+		job.Done()
+	}
+	if !queue.Idle() {
+		t.Error("expected queue to be idle")
+	}
+	// Only written segment is left
+	if files, _ := ioutil.ReadDir(dir); len(files) != 2 {
+		t.Errorf("expected 2 files, got %d", len(files))
+	}
+}
+
+// TestQueueCorruptedSegment -
+func TestQueueCorruptedSegment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	queue, err := NewQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+	for i := 0; i < 3; i++ {
+		if err := queue.Schedule(context.Background(), &crawl.Request{URL: fmt.Sprintf("http://example.com/%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Corrupt separators of the first and the last record
+	seg := queue.segments[0]
+	body, err := ioutil.ReadFile(queue.segmentPath(seg.id, "seg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []int{bytes.IndexByte(body, '\n'), len(body) - 1} {
+		if _, err := seg.file.WriteAt([]byte{' '}, int64(offset)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Corrupted records are skipped and writing continues in a new segment
+	jobs := make(chan crawl.Job)
+	go func() {
+		job, err := queue.Get()
+		if err != nil {
+			t.Error(err)
+		}
+		jobs <- job
+	}()
+	// Wait for corrupted segment to be detected
+	for rotated := false; !rotated; time.Sleep(time.Millisecond) {
+		queue.mutex.Lock()
+		rotated = queue.reader.id == 1
+		queue.mutex.Unlock()
+	}
+	if err := queue.Schedule(context.Background(), &crawl.Request{URL: "http://example.com/3"}); err != nil {
+		t.Fatal(err)
+	}
+	job := <-jobs
+	if job.Request().URL != "http://example.com/3" {
+		t.Fatalf("unexpected request %s", job.Request().URL)
+	}
+	if queue.Idle() {
+		t.Error("expected queue not to be idle before job is done")
+	}
+	job.Done()
+	if !queue.Idle() {
+		t.Error("expected queue to be idle")
+	}
+}
+
+// TestQueueRollback -
+func TestQueueRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	queue, err := NewQueue(dir, WithSync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+	if err := queue.Schedule(context.Background(), &crawl.Request{URL: "http://example.com/0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Partially written record is removed
+	seg := queue.segments[0]
+	if _, err := seg.file.Write([]byte(`{"request":{"url":"http://exa`)); err != nil {
+		t.Fatal(err)
+	}
+	queue.rollback(seg, io.ErrShortWrite)
+	if err := queue.Schedule(context.Background(), &crawl.Request{URL: "http://example.com/1"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		job, err := queue.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("http://example.com/%d", i); job.Request().URL != expected {
+			t.Fatalf("expected %s, got %s", expected, job.Request().URL)
+		}
+		job.Done()
+	}
+
+	// Writing continues in a new segment if record can't be removed
+	seg.file.Close()
+	if err := queue.Schedule(context.Background(), &crawl.Request{URL: "http://example.com/2"}); err == nil {
+		t.Fatal("expected write error")
+	}
+	if err := queue.Schedule(context.Background(), &crawl.Request{URL: "http://example.com/3"}); err != nil {
+		t.Fatal(err)
+	}
+	job, err := queue.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Request().URL != "http://example.com/3" {
+		t.Errorf("unexpected request %s", job.Request().URL)
+	}
+}
@@ -0,0 +1,65 @@
+// Package envelope implements serialization of crawl requests
+// with their context for persistent and distributed queues.
+package envelope
+
+import (
+	"time"
+
+	"google.golang.org/grpc/metadata"
+
+	"golang.org/x/net/context"
+
+	"github.com/crackcomm/crawl"
+)
+
+// Request - Request with values of its context as it is stored in a queue.
+type Request struct {
+	Request  *crawl.Request `json:"request,omitempty"`
+	Deadline time.Time      `json:"deadline,omitempty"`
+	Metadata metadata.MD    `json:"metadata,omitempty"`
+	// Proxy - Proxies from request context.
+	Proxy []string `json:"proxy,omitempty"`
+}
+
+// New - Creates request envelope with deadline, metadata
+// and proxies from context. Trace context is stored in request
+// Trace, it is set from context if request has none.
+func New(ctx context.Context, req *crawl.Request) *Request {
+	if trace, ok := crawl.TraceFromContext(ctx); ok && req.Trace == nil {
+		traced := *req
+		traced.Trace = trace
+		req = &traced
+	}
+	md, _ := metadata.FromContext(ctx)
+	proxy, _ := crawl.ProxyFromContext(ctx)
+	r := &Request{Request: req, Metadata: md, Proxy: proxy}
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Deadline = deadline
+	}
+	return r
+}
+
+// Expired - Checks if request deadline exceeded.
+func (r *Request) Expired() bool {
+	return !r.Deadline.IsZero() && time.Now().After(r.Deadline)
+}
+
+// Context - Returns context with request deadline, metadata
+// and proxies set.
+func (r *Request) Context(ctx context.Context) context.Context {
+	// Set request deadline
+	if !r.Deadline.IsZero() {
+		ctx, _ = context.WithDeadline(ctx, r.Deadline)
+	}
+
+	// Set metadata in context
+	if len(r.Metadata) > 0 {
+		ctx = metadata.NewContext(ctx, r.Metadata)
+	}
+
+	// Set proxies in context
+	if len(r.Proxy) > 0 {
+		ctx = crawl.WithProxy(ctx, r.Proxy...)
+	}
+	return ctx
+}
@@ -0,0 +1,35 @@
+package envelope
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/crackcomm/crawl"
+)
+
+// TestEnvelopeTrace -
+func TestEnvelopeTrace(t *testing.T) {
+	trace := crawl.NewTraceContext()
+	ctx := crawl.WithProxy(crawl.WithTrace(context.Background(), trace), "http://proxy:8080")
+	req := &crawl.Request{URL: "http://example.com/"}
+	body, err := json.Marshal(New(ctx, req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Trace != nil {
+		t.Error("expected scheduled request not to be modified")
+	}
+
+	r := new(Request)
+	if err := json.Unmarshal(body, r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Request.Trace == nil || r.Request.Trace.TraceID != trace.TraceID {
+		t.Errorf("expected trace in request, got %+v", r.Request.Trace)
+	}
+	if addrs, _ := crawl.ProxyFromContext(r.Context(context.Background())); len(addrs) != 1 {
+		t.Errorf("unexpected proxies %q", addrs)
+	}
+}
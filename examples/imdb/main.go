@@ -6,6 +6,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"golang.org/x/net/context"
 
@@ -19,6 +20,8 @@ func main() {
 		crawl.WithQueue(crawl.NewQueue(1000)),
 		crawl.WithConcurrency(200),
 		crawl.WithSpiders(imdb.Spider),
+		crawl.WithRetryPolicy(crawl.DefaultRetryPolicy),
+		crawl.WithExporters(crawl.NewJSONLinesExporter(os.Stdout)),
 	)
 
 	if err := c.Schedule(context.Background(), &crawl.Request{
@@ -31,7 +34,7 @@ func main() {
 	log.Print("Starting crawl")
 
 	// Its up to You how you want to handle errors
-	// You can reschedule request or ignore that
+	// Failed requests are retried and errors are received after last attempt
 	go func() {
 		for err := range c.Errors() {
 			log.Printf("Crawl error: %v", err)
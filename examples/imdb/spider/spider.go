@@ -3,9 +3,8 @@ package spider
 
 import (
 	"fmt"
-	"log"
+	"regexp"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/crackcomm/crawl"
 	"golang.org/x/net/context"
 )
@@ -32,16 +31,22 @@ func (spider *imdbSpider) List(ctx context.Context, resp *crawl.Response) (err e
 		return err
 	}
 
-	resp.Query().Find("table.chart td.titleColumn a").Each(func(_ int, link *goquery.Selection) {
-		href, _ := link.Attr("href")
-		spider.Crawler.Schedule(ctx, &crawl.Request{
-			URL:       href,
-			Referer:   resp.URL().String(),
-			Callbacks: crawl.Callbacks(Movie),
-		})
-	})
+	return movieLinks.Follow(ctx, spider.Crawler, resp)
+}
 
-	return
+// movieLinks - Follows links from list to movies.
+var movieLinks = crawl.FollowRule{
+	Extractor: &crawl.LinkExtractor{
+		Selectors: []string{"table.chart td.titleColumn a"},
+		Allow:     []*regexp.Regexp{regexp.MustCompile(`/title/tt\d+`)},
+	},
+	Callbacks: crawl.Callbacks(Movie),
+}
+
+// MovieItem - IMDB movie item.
+type MovieItem struct {
+	Title string `crawl:"h1.header span[itemprop=name]:nth-of-type(1),required" json:"title"`
+	Year  int    `crawl:"h1.header span a" json:"year,omitempty"`
 }
 
 func (spider *imdbSpider) Movie(ctx context.Context, resp *crawl.Response) (err error) {
@@ -49,11 +54,12 @@ func (spider *imdbSpider) Movie(ctx context.Context, resp *crawl.Response) (err
 		return err
 	}
 
-	title := crawl.Text(resp, "h1.header span[itemprop=name]:nth-of-type(1)")
-	year := crawl.Text(resp, "h1.header span a")
-	log.Printf("title=%q year=%s", title, year)
+	movie := new(MovieItem)
+	if err := crawl.Extract(resp, movie); err != nil {
+		return err
+	}
 
-	return
+	return crawl.Emit(ctx, movie)
 }
 
 func (spider *imdbSpider) checkError(resp *crawl.Response) (err error) {
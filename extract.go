@@ -0,0 +1,238 @@
+package crawl
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ExtractError - Aggregated errors of Extract.
+type ExtractError struct {
+	Errors []*FieldError
+}
+
+// Error - Returns all field errors separated by semicolon.
+func (err *ExtractError) Error() string {
+	msgs := make([]string, len(err.Errors))
+	for i, e := range err.Errors {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// FieldError - Error of extracting a single field.
+type FieldError struct {
+	// Field - Path of a field, eg. "Movie.Cast[1].Name".
+	Field string
+	// Selector - Selector of a field.
+	Selector string
+	Err      error
+}
+
+// Error - Returns field error message.
+func (err *FieldError) Error() string {
+	return fmt.Sprintf("%s (%q): %v", err.Field, err.Selector, err.Err)
+}
+
+// errNotFound - Error of required field that was not found.
+var errNotFound = errors.New("node not found")
+
+// Extract - Fills structure from finder using `crawl` struct tags.
+// Tag format is a selector followed by comma separated options:
+//
+//	Title  string   `crawl:"h1.header span"`
+//	Link   string   `crawl:"a,attr=href,resolve"`
+//	Rating float64  `crawl:".rating,required"`
+//	Genres []string `crawl:".genre a"`
+//	Cast   []Actor  `crawl:"table.cast tr"`
+//
+// Text is extracted same way as in Text(). Options are "attr=name"
+// to extract attribute, "resolve" to resolve URL relative to response
+// URL and "required" to fail when node is not found. Nested structures
+// are extracted relative to selected node, slices of structures are
+// extracted for each selected node. Empty selector selects current node.
+// Numbers are parsed same way as in ParseFloat() and ParseUint().
+// Not found nodes result in zero values unless required.
+// All field errors are returned in *ExtractError.
+func Extract(finder Finder, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New("crawl: Extract requires a pointer to a structure")
+	}
+	e := new(extractor)
+	if resp, ok := finder.(*Response); ok && resp.Response != nil {
+		e.base = resp.URL()
+	}
+	e.extractStruct(finder, value.Elem(), value.Elem().Type().Name())
+	if len(e.errors) > 0 {
+		return &ExtractError{Errors: e.errors}
+	}
+	return nil
+}
+
+// extractTag - Parsed `crawl` tag.
+type extractTag struct {
+	selector string
+	attr     string
+	resolve  bool
+	required bool
+}
+
+// parseExtractTag - Parses tag. Options are taken from the end
+// so selector can contain commas.
+func parseExtractTag(tag string) (t extractTag) {
+	parts := strings.Split(tag, ",")
+	for len(parts) > 1 {
+		opt := strings.TrimSpace(parts[len(parts)-1])
+		switch {
+		case strings.HasPrefix(opt, "attr="):
+			t.attr = strings.TrimPrefix(opt, "attr=")
+		case opt == "resolve":
+			t.resolve = true
+		case opt == "required":
+			t.required = true
+		default:
+			t.selector = strings.TrimSpace(strings.Join(parts, ","))
+			return
+		}
+		parts = parts[:len(parts)-1]
+	}
+	t.selector = strings.TrimSpace(parts[0])
+	return
+}
+
+type extractor struct {
+	base   *url.URL
+	errors []*FieldError
+}
+
+func (e *extractor) fail(field string, tag extractTag, err error) {
+	e.errors = append(e.errors, &FieldError{Field: field, Selector: tag.selector, Err: err})
+}
+
+func (e *extractor) extractStruct(finder Finder, value reflect.Value, path string) {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := field.Tag.Lookup("crawl")
+		if !ok || field.PkgPath != "" {
+			continue
+		}
+		e.extractField(finder, value.Field(i), parseExtractTag(tag), path+"."+field.Name)
+	}
+}
+
+func (e *extractor) extractField(finder Finder, value reflect.Value, tag extractTag, path string) {
+	nodes := findNodes(finder, tag.selector)
+	if nodes.Length() == 0 {
+		if tag.required {
+			e.fail(path, tag, errNotFound)
+		}
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		e.extractStruct(nodes.First(), value, path)
+	case reflect.Slice:
+		elem := value.Type().Elem()
+		slice := reflect.MakeSlice(value.Type(), 0, nodes.Length())
+		nodes.Each(func(i int, node *goquery.Selection) {
+			item := reflect.New(elem).Elem()
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if elem.Kind() == reflect.Struct {
+				e.extractStruct(node, item, itemPath)
+			} else if err := e.setValue(item, e.nodeValue(node, tag)); err != nil {
+				e.fail(itemPath, tag, err)
+			}
+			slice = reflect.Append(slice, item)
+		})
+		value.Set(slice)
+	default:
+		if err := e.setValue(value, e.nodeValue(nodes, tag)); err != nil {
+			e.fail(path, tag, err)
+		}
+	}
+}
+
+// nodeValue - Returns node text or attribute value.
+func (e *extractor) nodeValue(node *goquery.Selection, tag extractTag) string {
+	var text string
+	if tag.attr != "" {
+		text, _ = node.Attr(tag.attr)
+		text = strings.TrimSpace(text)
+	} else {
+		text = strings.Join(strings.Fields(getText(node)), " ")
+	}
+	if tag.resolve && e.base != nil && text != "" {
+		if u, err := url.Parse(text); err == nil {
+			text = e.base.ResolveReference(u).String()
+		}
+	}
+	return text
+}
+
+// setValue - Sets parsed text on a value.
+// Empty text results in zero value.
+func (e *extractor) setValue(value reflect.Value, text string) (err error) {
+	if text == "" && value.Kind() != reflect.String {
+		return
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(text); err == nil {
+			value.SetBool(b)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		text = strings.Replace(text, ",", ".", -1)
+		if f, err = strconv.ParseFloat(text, value.Type().Bits()); err == nil {
+			value.SetFloat(f)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(cleanNumber(text), 10, value.Type().Bits()); err == nil {
+			value.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(cleanNumber(text), 10, value.Type().Bits()); err == nil {
+			value.SetUint(n)
+		}
+	default:
+		err = fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return
+}
+
+// cleanNumber - Removes commas and spaces from integer text.
+func cleanNumber(text string) string {
+	text = strings.Replace(text, ",", "", -1)
+	return strings.Replace(text, " ", "", -1)
+}
+
+// findNodes - Finds nodes, empty selector selects finder node.
+func findNodes(finder Finder, selector string) *goquery.Selection {
+	if selector != "" {
+		return finder.Find(selector)
+	}
+	switch node := finder.(type) {
+	case *goquery.Selection:
+		return node
+	case *goquery.Document:
+		return node.Selection
+	case *Response:
+		if node.Query() != nil {
+			return node.Query().Selection
+		}
+	}
+	return new(goquery.Selection)
+}
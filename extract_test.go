@@ -0,0 +1,74 @@
+package crawl
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type testMovie struct {
+	Title  string      `crawl:"h1.header span"`
+	Year   int         `crawl:"h1.header a"`
+	Votes  uint64      `crawl:".votes"`
+	Rating float64     `crawl:".rating"`
+	Link   string      `crawl:"a.self,attr=href,resolve"`
+	Genres []string    `crawl:".genres a, .genre"`
+	Cast   []testActor `crawl:"table.cast tr"`
+	Budget int         `crawl:".budget,required"`
+}
+
+type testActor struct {
+	Name string `crawl:"td.name"`
+	Age  int    `crawl:"td.age"`
+}
+
+const testMovieHTML = `<html><body>
+<h1 class="header"><span>The  Movie</span> <a>1994</a></h1>
+<div class="votes">1, 234 567</div>
+<div class="rating">9,2</div>
+<a class="self" href="/title/1/">self</a>
+<div class="genres"><a>Crime</a><a>Drama</a></div>
+<table class="cast">
+<tr><td class="name">Actor One</td><td class="age">40</td></tr>
+<tr><td class="name">Actor Two</td><td class="age">unknown</td></tr>
+</table>
+</body></html>`
+
+// TestExtract -
+func TestExtract(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testMovieHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://www.imdb.com/chart/")
+	resp := &Response{Response: &http.Response{Request: &http.Request{URL: u}}, doc: doc}
+
+	movie := new(testMovie)
+	err = Extract(resp, movie)
+	if movie.Title != "The Movie" || movie.Year != 1994 || movie.Votes != 1234567 || movie.Rating != 9.2 {
+		t.Errorf("unexpected movie %+v", movie)
+	}
+	if movie.Link != "http://www.imdb.com/title/1/" {
+		t.Errorf("unexpected link %q", movie.Link)
+	}
+	if len(movie.Genres) != 2 || movie.Genres[1] != "Drama" {
+		t.Errorf("unexpected genres %v", movie.Genres)
+	}
+	if len(movie.Cast) != 2 || movie.Cast[0].Name != "Actor One" || movie.Cast[0].Age != 40 {
+		t.Errorf("unexpected cast %+v", movie.Cast)
+	}
+
+	extractErr, ok := err.(*ExtractError)
+	if !ok || len(extractErr.Errors) != 2 {
+		t.Fatalf("expected 2 field errors, got %v", err)
+	}
+	if field := extractErr.Errors[0].Field; field != "testMovie.Cast[1].Age" {
+		t.Errorf("unexpected field %q", field)
+	}
+	if field := extractErr.Errors[1].Field; field != "testMovie.Budget" {
+		t.Errorf("unexpected field %q", field)
+	}
+}
@@ -0,0 +1,41 @@
+// Package glogger implements crawl logger using glog.
+package glogger
+
+import (
+	"github.com/golang/glog"
+
+	"github.com/crackcomm/crawl"
+)
+
+// DebugVerbosity - Verbosity level of debug messages.
+var DebugVerbosity glog.Level = 3
+
+// New - Creates crawl logger writing to glog.
+// Debug messages are logged using glog.V(DebugVerbosity).
+func New() crawl.Logger {
+	return logger{}
+}
+
+type logger struct{}
+
+func (logger) Enabled(level crawl.Level) bool {
+	if level == crawl.LevelDebug {
+		return bool(glog.V(DebugVerbosity))
+	}
+	return true
+}
+
+func (logger) Log(level crawl.Level, msg string, fields ...crawl.Field) {
+	switch level {
+	case crawl.LevelDebug:
+		if glog.V(DebugVerbosity) {
+			glog.InfoDepth(1, crawl.FormatFields(msg, fields...))
+		}
+	case crawl.LevelInfo:
+		glog.InfoDepth(1, crawl.FormatFields(msg, fields...))
+	case crawl.LevelWarning:
+		glog.WarningDepth(1, crawl.FormatFields(msg, fields...))
+	default:
+		glog.ErrorDepth(1, crawl.FormatFields(msg, fields...))
+	}
+}
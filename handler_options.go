@@ -0,0 +1,42 @@
+package crawl
+
+import "golang.org/x/net/context"
+
+// HandlerOption - Handler registration option.
+type HandlerOption func(*handlerOptions)
+
+// handlerOptions - Handler routing options.
+type handlerOptions struct {
+	minStatus, maxStatus int
+}
+
+// OnStatus - Executes handler only for responses
+// with status code in range from min to max (inclusive).
+func OnStatus(min, max int) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.minStatus = min
+		opts.maxStatus = max
+	}
+}
+
+// OnSuccess - Executes handler only for responses with 2xx status code.
+func OnSuccess() HandlerOption {
+	return OnStatus(200, 299)
+}
+
+// routeHandler - Wraps handler to respect routing options.
+func routeHandler(h Handler, opts ...HandlerOption) Handler {
+	if len(opts) == 0 {
+		return h
+	}
+	o := new(handlerOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(ctx context.Context, resp *Response) error {
+		if code := resp.StatusCode; code < o.minStatus || (o.maxStatus > 0 && code > o.maxStatus) {
+			return nil
+		}
+		return h(ctx, resp)
+	}
+}
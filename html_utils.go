@@ -60,9 +60,7 @@ func ParseFloat(n Finder, selector string) (res float64, err error) {
 // Returned error source is strconv.ParseUint.
 func ParseUint(n Finder, selector string) (res uint64, err error) {
 	if text := Text(n, selector); text != "" {
-		text = strings.Replace(text, ",", "", -1)
-		text = strings.Replace(text, " ", "", -1)
-		res, err = strconv.ParseUint(text, 10, 64)
+		res, err = strconv.ParseUint(cleanNumber(text), 10, 64)
 	}
 	return
 }
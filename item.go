@@ -0,0 +1,123 @@
+package crawl
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ErrDropItem - Error returned by processor to drop an item.
+// It is not returned from Emit.
+var ErrDropItem = errors.New("crawl: drop item")
+
+// ErrNoPipeline - Error returned from Emit when crawler has no processors
+// nor exporters or when context is not a handler context.
+var ErrNoPipeline = errors.New("crawl: no item pipeline")
+
+// Item - Scraped item with its provenance.
+type Item struct {
+	// Data - Scraped data.
+	Data interface{} `json:"data,omitempty"`
+	// URL - URL of response item was scraped from.
+	URL string `json:"url,omitempty"`
+	// Callback - Name of callback item was emitted from.
+	Callback string `json:"callback,omitempty"`
+	// FetchTime - Time when response was received.
+	FetchTime time.Time `json:"fetch_time,omitempty"`
+}
+
+// Processor - Item processor executed before exporting an item.
+// It can modify an item, drop it by returning ErrDropItem
+// or fail by returning an error.
+type Processor func(context.Context, *Item) error
+
+// Exporter - Items exporter.
+type Exporter interface {
+	// Export - Exports an item.
+	Export(*Item) error
+
+	// Flush - Flushes exported items.
+	// It is called when crawler is stopped.
+	Flush() error
+}
+
+// Emit - Emits an item from a handler.
+// Item is passed through crawler processors and exporters.
+// Provenance of the item is taken from handler context.
+func Emit(ctx context.Context, data interface{}) error {
+	p, ok := ctx.Value(pipelineKey{}).(*pipeline)
+	if !ok {
+		return ErrNoPipeline
+	}
+	item := &Item{Data: data}
+	if resp, ok := ResponseFromContext(ctx); ok {
+		item.URL = resp.URL().String()
+		item.FetchTime = resp.FetchTime()
+	}
+	item.Callback, _ = CallbackFromContext(ctx)
+	return p.process(ctx, item)
+}
+
+// DedupItems - Returns processor dropping items with duplicate keys.
+func DedupItems(key func(*Item) string) Processor {
+	seen := make(map[string]struct{})
+	mutex := new(sync.Mutex)
+	return func(_ context.Context, item *Item) error {
+		k := key(item)
+		mutex.Lock()
+		defer mutex.Unlock()
+		if _, ok := seen[k]; ok {
+			return ErrDropItem
+		}
+		seen[k] = struct{}{}
+		return nil
+	}
+}
+
+// pipelineKey - Context key of items pipeline.
+type pipelineKey struct{}
+
+func withPipeline(ctx context.Context, p *pipeline) context.Context {
+	return context.WithValue(ctx, pipelineKey{}, p)
+}
+
+// pipeline - Items processors and exporters.
+type pipeline struct {
+	processors []Processor
+	exporters  []Exporter
+	// mutex - Exporters are not required to be safe for concurrent use.
+	mutex *sync.Mutex
+}
+
+// process - Processes and exports an item.
+func (p *pipeline) process(ctx context.Context, item *Item) (err error) {
+	for _, processor := range p.processors {
+		if err = processor(ctx, item); err == ErrDropItem {
+			return nil
+		} else if err != nil {
+			return
+		}
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, exporter := range p.exporters {
+		if err = exporter.Export(item); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// flush - Flushes all exporters and returns errors.
+func (p *pipeline) flush() (errs []error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, exporter := range p.exporters {
+		if err := exporter.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return
+}
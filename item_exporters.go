@@ -0,0 +1,117 @@
+package crawl
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// NewJSONLinesExporter - Creates exporter writing items as JSON lines.
+func NewJSONLinesExporter(w io.Writer) Exporter {
+	buf := bufio.NewWriter(w)
+	return &jsonLinesExporter{buf: buf, encoder: json.NewEncoder(buf)}
+}
+
+type jsonLinesExporter struct {
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (exporter *jsonLinesExporter) Export(item *Item) error {
+	return exporter.encoder.Encode(item)
+}
+
+func (exporter *jsonLinesExporter) Flush() error {
+	return exporter.buf.Flush()
+}
+
+// NewCSVExporter - Creates exporter writing items as CSV rows.
+// Columns are "url", "callback", "fetch_time" and given fields.
+// Fields are taken from item data which has to be a map
+// with string keys or a structure (by field name).
+func NewCSVExporter(w io.Writer, fields ...string) Exporter {
+	return &csvExporter{writer: csv.NewWriter(w), fields: fields}
+}
+
+type csvExporter struct {
+	writer *csv.Writer
+	fields []string
+	header bool
+}
+
+func (exporter *csvExporter) Export(item *Item) (err error) {
+	if !exporter.header {
+		header := append([]string{"url", "callback", "fetch_time"}, exporter.fields...)
+		if err = exporter.writer.Write(header); err != nil {
+			return
+		}
+		exporter.header = true
+	}
+	row := []string{item.URL, item.Callback, item.FetchTime.Format(time.RFC3339)}
+	for _, field := range exporter.fields {
+		row = append(row, itemField(item.Data, field))
+	}
+	return exporter.writer.Write(row)
+}
+
+func (exporter *csvExporter) Flush() error {
+	exporter.writer.Flush()
+	return exporter.writer.Error()
+}
+
+// itemField - Returns formatted field of a map or a structure.
+func itemField(data interface{}, name string) string {
+	v := reflect.Indirect(reflect.ValueOf(data))
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		} else {
+			return ""
+		}
+	case reflect.Struct:
+		v = v.FieldByName(name)
+	default:
+		return ""
+	}
+	if !v.IsValid() || !v.CanInterface() {
+		return ""
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// NewFileExporter - Creates exporter writing every item as JSON to
+// a separate file in a directory. File name is a hash of its content.
+func NewFileExporter(dir string) Exporter {
+	return &fileExporter{dir: dir}
+}
+
+type fileExporter struct {
+	dir string
+}
+
+func (exporter *fileExporter) Export(item *Item) (err error) {
+	body, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(exporter.dir, 0755); err != nil {
+		return
+	}
+	sum := sha1.Sum(body)
+	fname := filepath.Join(exporter.dir, hex.EncodeToString(sum[:])+".json")
+	return ioutil.WriteFile(fname, body, 0644)
+}
+
+func (exporter *fileExporter) Flush() error {
+	return nil
+}
@@ -0,0 +1,45 @@
+package crawl
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// TestItemPipeline -
+func TestItemPipeline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body><h1>Title</h1></body></html>")
+	}))
+	defer ts.Close()
+
+	out := new(bytes.Buffer)
+	c := New(
+		WithProcessors(DedupItems(func(item *Item) string { return item.URL })),
+		WithExporters(NewCSVExporter(out, "Title")),
+	)
+	c.Register("page", func(ctx context.Context, resp *Response) error {
+		return Emit(ctx, &struct{ Title string }{Text(resp, "h1")})
+	})
+	for i := 0; i < 2; i++ {
+		c.Schedule(context.Background(), &Request{URL: ts.URL, AllowDuplicate: true, Callbacks: Callbacks("page")})
+	}
+	c.Start()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one row, got %q", out.String())
+	}
+	if !strings.HasPrefix(lines[1], ts.URL+",page,") || !strings.HasSuffix(lines[1], ",Title") {
+		t.Errorf("unexpected row %q", lines[1])
+	}
+
+	if err := Emit(context.Background(), "item"); err != ErrNoPipeline {
+		t.Errorf("expected no pipeline error, got %v", err)
+	}
+}
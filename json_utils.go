@@ -0,0 +1,52 @@
+package crawl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// JSONPath - Looks up a value in decoded JSON using a path.
+// Path is a list of object keys and array indexes separated by dots,
+// indexes can be also written in brackets, "$" prefix is optional.
+// Example: "$.data.items[0].title" or "data.items.0.title".
+func JSONPath(v interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(path, "$")
+	path = strings.Replace(path, "[", ".", -1)
+	path = strings.Replace(path, "]", "", -1)
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = node[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// JSONString - Looks up a string value in decoded JSON.
+// Numbers and booleans are formatted, other values result in empty string.
+func JSONString(v interface{}, path string) string {
+	v, _ = JSONPath(v, path)
+	switch value := v.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	return ""
+}
@@ -0,0 +1,25 @@
+package crawl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestJSONPath -
+func TestJSONPath(t *testing.T) {
+	var v interface{}
+	json.Unmarshal([]byte(`{"data": {"items": [{"title": "a", "rating": 8.5}]}}`), &v)
+	for path, expected := range map[string]string{
+		"$.data.items[0].title": "a",
+		"data.items.0.rating":   "8.5",
+		"data.items[1].title":   "",
+		"data.missing":          "",
+	} {
+		if value := JSONString(v, path); value != expected {
+			t.Errorf("%s: expected %q, got %q", path, expected, value)
+		}
+	}
+	if _, ok := JSONPath(v, "data.items"); !ok {
+		t.Error("expected items to be found")
+	}
+}
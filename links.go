@@ -0,0 +1,189 @@
+package crawl
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/context"
+)
+
+// DefaultLinkSelectors - Selectors of nodes links are extracted from.
+var DefaultLinkSelectors = []string{
+	"a[href]",
+	"area[href]",
+	"link[rel=next][href]",
+}
+
+// DefaultDenyExtensions - Extensions of links which are not extracted
+// if LinkExtractor DenyExtensions is nil.
+var DefaultDenyExtensions = []string{
+	// images
+	"bmp", "gif", "ico", "jpeg", "jpg", "png", "svg", "tif", "tiff", "webp",
+	// audio and video
+	"avi", "flv", "m4a", "mkv", "mov", "mp3", "mp4", "mpeg", "mpg", "ogg", "wav", "webm", "wmv",
+	// documents
+	"doc", "docx", "odt", "pdf", "ppt", "pptx", "xls", "xlsx",
+	// archives and other
+	"7z", "apk", "bz2", "css", "dmg", "exe", "gz", "iso", "js", "rar", "tar", "zip",
+}
+
+// LinkExtractor - Extracts links from HTML responses.
+// Links are resolved relative to response URL, only http and https
+// links are extracted, every link is returned once.
+type LinkExtractor struct {
+	// Selectors - Selectors of nodes with href attribute.
+	// Default: DefaultLinkSelectors.
+	Selectors []string
+
+	// Allow - Link is extracted only if it matches any of expressions.
+	Allow []*regexp.Regexp
+	// Deny - Link is not extracted if it matches any of expressions.
+	Deny []*regexp.Regexp
+
+	// AllowDomains - Link is extracted only if its host is one of domains
+	// or their subdomains.
+	AllowDomains []string
+	// DenyDomains - Link is not extracted if its host is one of domains
+	// or their subdomains.
+	DenyDomains []string
+
+	// DenyExtensions - Link is not extracted if its path has one of extensions.
+	// Default: DefaultDenyExtensions, empty slice allows all extensions.
+	DenyExtensions []string
+
+	// KeepFragments - Keeps URL fragments which are stripped by default.
+	KeepFragments bool
+}
+
+// Extract - Extracts links from response.
+// Returns nil if response was not parsed as HTML.
+func (e *LinkExtractor) Extract(resp *Response) (links []string) {
+	if resp.Query() == nil {
+		return
+	}
+	selectors := e.Selectors
+	if selectors == nil {
+		selectors = DefaultLinkSelectors
+	}
+	seen := make(map[string]bool)
+	resolve := NodeResolveURL(resp)
+	for _, selector := range selectors {
+		resp.Find(selector).Each(func(i int, n *goquery.Selection) {
+			link := e.normalize(resolve(i, n))
+			if link == "" || seen[link] {
+				return
+			}
+			seen[link] = true
+			links = append(links, link)
+		})
+	}
+	return
+}
+
+// normalize - Returns normalized link or empty string if it is filtered.
+func (e *LinkExtractor) normalize(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	if !e.KeepFragments {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+	if !e.allowed(u) {
+		return ""
+	}
+	return u.String()
+}
+
+func (e *LinkExtractor) allowed(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	if len(e.AllowDomains) > 0 && !matchDomain(host, e.AllowDomains) {
+		return false
+	}
+	if matchDomain(host, e.DenyDomains) {
+		return false
+	}
+	extensions := e.DenyExtensions
+	if extensions == nil {
+		extensions = DefaultDenyExtensions
+	}
+	if ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), ".")); ext != "" {
+		for _, deny := range extensions {
+			if ext == strings.ToLower(strings.TrimPrefix(deny, ".")) {
+				return false
+			}
+		}
+	}
+	link := u.String()
+	if len(e.Allow) > 0 && !matchAny(link, e.Allow) {
+		return false
+	}
+	return !matchAny(link, e.Deny)
+}
+
+// matchDomain - Checks if host is one of domains or their subdomain.
+func matchDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(link string, exprs []*regexp.Regexp) bool {
+	for _, expr := range exprs {
+		if expr.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// FollowRule - Rule of following extracted links.
+type FollowRule struct {
+	// Extractor - Links extractor.
+	// Default: LinkExtractor with default settings.
+	Extractor *LinkExtractor
+
+	// Callbacks - Callbacks of scheduled requests.
+	Callbacks []string
+}
+
+// Follow - Extracts links from response and schedules requests
+// with response URL as a referer.
+func (rule FollowRule) Follow(ctx context.Context, c Crawler, resp *Response) (err error) {
+	extractor := rule.Extractor
+	if extractor == nil {
+		extractor = new(LinkExtractor)
+	}
+	referer := resp.URL().String()
+	for _, link := range extractor.Extract(resp) {
+		err = c.Schedule(ctx, &Request{
+			URL:       link,
+			Referer:   referer,
+			Callbacks: rule.Callbacks,
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Follow - Returns handler which follows links using rules.
+func Follow(c Crawler, rules ...FollowRule) Handler {
+	return func(ctx context.Context, resp *Response) (err error) {
+		for _, rule := range rules {
+			if err = rule.Follow(ctx, c, resp); err != nil {
+				return
+			}
+		}
+		return
+	}
+}
@@ -0,0 +1,80 @@
+package crawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// TestLinkExtractor -
+func TestLinkExtractor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><link rel="next" href="/page/2"></head><body>
+			<a href="/item/1#reviews">1</a>
+			<a href="/item/1">1 again</a>
+			<a href="http://sub.example.com/item/2">2</a>
+			<a href="http://other.com/item/3">3</a>
+			<a href="/item/4.pdf">4</a>
+			<a href="/login">login</a>
+			<a href="mailto:a@example.com">mail</a>
+			<map><area href="/item/5"></map>
+		</body></html>`)
+	}))
+	defer ts.Close()
+
+	c := New()
+	resp, err := c.Execute(context.Background(), &Request{URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &LinkExtractor{
+		Deny:        []*regexp.Regexp{regexp.MustCompile(`/login$`)},
+		DenyDomains: []string{"other.com"},
+	}
+	expected := []string{
+		ts.URL + "/item/1",
+		"http://sub.example.com/item/2",
+		ts.URL + "/item/5",
+		ts.URL + "/page/2",
+	}
+	if links := e.Extract(resp); !reflect.DeepEqual(links, expected) {
+		t.Errorf("expected %q, got %q", expected, links)
+	}
+
+	e = &LinkExtractor{AllowDomains: []string{"example.com"}}
+	if links := e.Extract(resp); !reflect.DeepEqual(links, []string{"http://sub.example.com/item/2"}) {
+		t.Errorf("unexpected links %q", links)
+	}
+}
+
+// TestFollow -
+func TestFollow(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/a">a</a><a href="/b">b</a>`)
+	}))
+	defer ts.Close()
+
+	c := New(WithFollow("list", FollowRule{Callbacks: Callbacks("item")}))
+	if _, err := c.Execute(context.Background(), &Request{URL: ts.URL, Callbacks: Callbacks("list")}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	queue := c.(*crawl).queue
+	for _, path := range []string{"/a", "/b"} {
+		job, err := queue.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := job.Request()
+		if req.URL != ts.URL+path || req.Referer != ts.URL || req.Depth != 1 || !reflect.DeepEqual(req.Callbacks, Callbacks("item")) {
+			t.Errorf("unexpected request %+v", req)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package crawl
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Level - Logging level.
+type Level int
+
+const (
+	// LevelDebug - Debugging messages, eg. every request.
+	LevelDebug Level = iota
+	// LevelInfo - Informational messages, eg. crawler started.
+	LevelInfo
+	// LevelWarning - Warnings, eg. failed request.
+	LevelWarning
+	// LevelError - Errors, eg. queue errors.
+	LevelError
+)
+
+// String - Returns level name.
+func (level Level) String() string {
+	switch level {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarning:
+		return "WARNING"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(level))
+}
+
+// Field - Structured log field.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F - Creates structured log field.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger - Structured, leveled logger.
+type Logger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+// LevelEnabler - Logger which reports if messages of a level are logged.
+// It is used to skip building fields of messages which are discarded.
+type LevelEnabler interface {
+	Enabled(Level) bool
+}
+
+// Enabled - Checks if logger logs messages of a level.
+// Loggers not implementing LevelEnabler log all levels.
+func Enabled(logger Logger, level Level) bool {
+	if l, ok := logger.(LevelEnabler); ok {
+		return l.Enabled(level)
+	}
+	return true
+}
+
+// NopLogger - Logger which discards all messages.
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(Level, string, ...Field) {}
+func (nopLogger) Enabled(Level) bool          { return false }
+
+// NewStdLogger - Creates logger writing messages of level
+// equal or greater than min to standard library logger.
+// Messages are formatted as: `LEVEL message key=value key=value`.
+func NewStdLogger(logger *log.Logger, min Level) Logger {
+	return &stdLogger{logger: logger, min: min}
+}
+
+type stdLogger struct {
+	logger *log.Logger
+	min    Level
+}
+
+func (l *stdLogger) Enabled(level Level) bool {
+	return level >= l.min
+}
+
+func (l *stdLogger) Log(level Level, msg string, fields ...Field) {
+	if level < l.min {
+		return
+	}
+	l.logger.Print(level.String() + " " + FormatFields(msg, fields...))
+}
+
+// FormatFields - Formats message with fields as: `message key=value`.
+// Values containing spaces or quotes are quoted.
+func FormatFields(msg string, fields ...Field) string {
+	b := bytes.NewBufferString(msg)
+	for _, field := range fields {
+		value := fmt.Sprint(field.Value)
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(b, " %s=%s", field.Key, value)
+	}
+	return b.String()
+}
+
+// requestFields - Returns request log fields.
+func requestFields(req *Request, fields ...Field) []Field {
+	list := []Field{
+		F("url", req.URL),
+		F("method", req.GetMethod()),
+	}
+	if len(req.Callbacks) > 0 {
+		list = append(list, F("callbacks", strings.Join(req.Callbacks, ",")))
+	}
+	if req.Attempt > 0 {
+		list = append(list, F("attempt", req.Attempt))
+	}
+	return append(list, fields...)
+}
+
+// durationField - Returns duration field rounded to milliseconds.
+func durationField(start time.Time) Field {
+	return F("duration", time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,44 @@
+package crawl
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// TestLogger -
+func TestLogger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	out := new(bytes.Buffer)
+	c := New(WithLogger(NewStdLogger(log.New(out, "", 0), LevelDebug)))
+	c.Execute(context.Background(), &Request{URL: ts.URL, Callbacks: Callbacks("a", "b")})
+
+	expected := "DEBUG response received url=" + ts.URL + " method=GET callbacks=a,b status=404 duration="
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("expected %q in log, got %q", expected, out.String())
+	}
+
+	out.Reset()
+	NewStdLogger(log.New(out, "", 0), LevelInfo).Log(LevelDebug, "skipped")
+	if out.Len() != 0 {
+		t.Errorf("expected debug message to be skipped, got %q", out.String())
+	}
+	if Enabled(NopLogger, LevelError) || Enabled(NewStdLogger(log.New(out, "", 0), LevelInfo), LevelDebug) {
+		t.Error("expected level to be disabled")
+	}
+	if !Enabled(NewStdLogger(log.New(out, "", 0), LevelInfo), LevelInfo) {
+		t.Error("expected level to be enabled")
+	}
+	if s := FormatFields("msg", F("error", "not found"), F("empty", "")); s != `msg error="not found" empty=""` {
+		t.Errorf("unexpected format %q", s)
+	}
+}
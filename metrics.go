@@ -0,0 +1,86 @@
+package crawl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MetricsHandler - Returns http handler exposing crawler statistics
+// in Prometheus text format.
+func MetricsHandler(c Crawler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteMetrics(w, c.Stats())
+	})
+}
+
+// WriteMetrics - Writes statistics in Prometheus text format.
+func WriteMetrics(w io.Writer, stats *Stats) error {
+	b := bufio.NewWriter(w)
+	counter := func(name, help string, value int64) {
+		writeMetricHeader(b, name, help, "counter")
+		fmt.Fprintf(b, "%s %d\n", name, value)
+	}
+	gauge := func(name, help string, value int64) {
+		writeMetricHeader(b, name, help, "gauge")
+		fmt.Fprintf(b, "%s %d\n", name, value)
+	}
+
+	counter("crawl_requests_scheduled_total", "Number of scheduled requests.", stats.Scheduled)
+	counter("crawl_requests_started_total", "Number of started requests.", stats.Started)
+	counter("crawl_requests_completed_total", "Number of completed requests.", stats.Completed)
+	counter("crawl_requests_failed_total", "Number of failed requests.", stats.Failed)
+	counter("crawl_requests_retried_total", "Number of retried requests.", stats.Retried)
+	counter("crawl_panics_total", "Number of recovered panics.", stats.Panics)
+	counter("crawl_downloaded_bytes_total", "Number of downloaded response body bytes.", stats.BytesDownloaded)
+
+	writeMetricHeader(b, "crawl_responses_total", "Number of responses by status code.", "counter")
+	codes := make([]int, 0, len(stats.StatusCodes))
+	for code := range stats.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(b, "crawl_responses_total{code=\"%d\"} %d\n", code, stats.StatusCodes[code])
+	}
+
+	writeHistograms(b, "crawl_response_duration_seconds", "Latency of receiving response headers.", "host", stats.HostLatency)
+	writeHistograms(b, "crawl_handler_duration_seconds", "Handlers execution time.", "callback", stats.CallbackLatency)
+
+	gauge("crawl_queue_depth", "Number of requests waiting in the queue.", int64(stats.QueueDepth))
+	gauge("crawl_in_flight", "Number of requests being executed.", stats.InFlight)
+	return b.Flush()
+}
+
+func writeMetricHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeHistograms(w io.Writer, name, help, label string, histograms map[string]*Histogram) {
+	writeMetricHeader(w, name, help, "histogram")
+	keys := make([]string, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		h := histograms[key]
+		value := escapeLabel(key)
+		for i, bound := range h.Buckets {
+			fmt.Fprintf(w, "%s_bucket{%s=\"%s\",le=\"%s\"} %d\n", name, label, value, strconv.FormatFloat(bound, 'g', -1, 64), h.Counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s=\"%s\",le=\"+Inf\"} %d\n", name, label, value, h.Count)
+		fmt.Fprintf(w, "%s_sum{%s=\"%s\"} %s\n", name, label, value, strconv.FormatFloat(h.Sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s=\"%s\"} %d\n", name, label, value, h.Count)
+	}
+}
+
+// escapeLabel - Escapes label value in Prometheus text format.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
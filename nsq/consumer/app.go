@@ -2,15 +2,17 @@
 package consumer
 
 import (
+	"net/http"
 	"os"
 	"os/signal"
 	"time"
 
-	"github.com/golang/glog"
+	"golang.org/x/net/context"
 	"gopkg.in/urfave/cli.v2"
 
 	clinsq "github.com/crackcomm/cli-nsq"
 	"github.com/crackcomm/crawl"
+	"github.com/crackcomm/crawl/glogger"
 	"github.com/crackcomm/crawl/nsq/nsqcrawl"
 )
 
@@ -28,6 +30,10 @@ type App struct {
 	// before - Flag requirements checking.
 	before func(c *App) error
 
+	// logger - App, queue and crawler logger.
+	// It can be changed using WithLogger()
+	logger crawl.Logger
+
 	// crawler - Accessed using Crawler() which constructs it on first call
 	// using parameters from command line.
 	crawler crawl.Crawler
@@ -61,11 +67,22 @@ var Flags = []cli.Flag{
 		Value:   30,
 		EnvVars: []string{"TIMEOUT"},
 	},
+	&cli.IntFlag{
+		Name:    "shutdown-timeout",
+		Usage:   "in-flight requests shutdown timeout in seconds",
+		Value:   30,
+		EnvVars: []string{"SHUTDOWN_TIMEOUT"},
+	},
+	&cli.StringFlag{
+		Name:    "metrics-addr",
+		Usage:   "listening address of prometheus /metrics endpoint (disabled if empty)",
+		EnvVars: []string{"METRICS_ADDR"},
+	},
 }
 
 // New - Creates nsq consumer app.
 func New(opts ...Option) *cli.App {
-	app := &App{opts: opts}
+	app := &App{opts: opts, logger: glogger.New()}
 	cliapp := (&cli.App{})
 	cliapp.Name = "crawler"
 	cliapp.HelpName = cliapp.Name
@@ -84,6 +101,7 @@ func (app *App) Action(c *cli.Context) error {
 	for _, opt := range app.opts {
 		opt(app)
 	}
+	nsqcrawl.WithLogger(app.logger)(app.Queue)
 
 	if err := app.Before(c); err != nil {
 		return err
@@ -105,9 +123,21 @@ func (app *App) Action(c *cli.Context) error {
 		return err
 	}
 
+	// Serve crawler metrics
+	if addr := c.String("metrics-addr"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", crawl.MetricsHandler(crawler))
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				app.logger.Log(crawl.LevelError, "metrics server error", crawl.F("error", err))
+			}
+		}()
+		app.logger.Log(crawl.LevelInfo, "serving metrics", crawl.F("addr", addr))
+	}
+
 	go func() {
 		for err := range crawler.Errors() {
-			glog.Warningf("crawl %v", err)
+			app.logger.Log(crawl.LevelWarning, "crawl error", crawl.F("error", err))
 		}
 	}()
 
@@ -117,7 +147,7 @@ func (app *App) Action(c *cli.Context) error {
 		done <- true
 	}()
 
-	glog.Infof("Started crawler (topic=%q)", c.String("topic"))
+	app.logger.Log(crawl.LevelInfo, "started crawler", crawl.F("topic", c.String("topic")))
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt)
@@ -125,11 +155,14 @@ func (app *App) Action(c *cli.Context) error {
 	for {
 		select {
 		case <-done:
-			glog.Info("Crawler closed")
+			app.logger.Log(crawl.LevelInfo, "crawler closed")
 			return nil
 		case s := <-sig:
-			glog.Infof("Received signal %v, closing crawler", s)
-			return app.Queue.Close()
+			app.logger.Log(crawl.LevelInfo, "closing crawler", crawl.F("signal", s))
+			timeout := time.Duration(c.Int("shutdown-timeout")) * time.Second
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			return crawler.Shutdown(ctx)
 		}
 	}
 }
@@ -162,5 +195,6 @@ func crawlerConstructor(app *App) crawl.Crawler {
 		crawl.WithQueue(app.Queue),
 		crawl.WithConcurrency(app.Ctx.Int("concurrency")),
 		crawl.WithDefaultTimeout(time.Duration(app.Ctx.Int("timeout"))*time.Second),
+		crawl.WithLogger(app.logger),
 	)
 }
@@ -38,9 +38,9 @@ func WithSpiders(spiders ...Spider) Option {
 
 // WithHandler - Registers crawler handler.
 // It has to be set after WithCrawler (if any).
-func WithHandler(name string, h crawl.Handler) Option {
+func WithHandler(name string, h crawl.Handler, opts ...crawl.HandlerOption) Option {
 	return func(app *App) {
-		app.Crawler().Register(name, h)
+		app.Crawler().Register(name, h, opts...)
 	}
 }
 
@@ -54,6 +54,25 @@ func WithMiddlewares(middlewares ...crawl.Middleware) Option {
 	}
 }
 
+// WithResponseMiddlewares - Registers response middlewares on a crawler.
+// It has to be set after WithCrawler (if any).
+func WithResponseMiddlewares(middlewares ...crawl.ResponseMiddleware) Option {
+	return func(app *App) {
+		for _, middleware := range middlewares {
+			app.Crawler().ResponseMiddleware(middleware)
+		}
+	}
+}
+
+// WithLogger - Sets app, queue and default crawler logger.
+// It has to be set before options constructing a crawler.
+// Default: glog logger.
+func WithLogger(logger crawl.Logger) Option {
+	return func(app *App) {
+		app.logger = logger
+	}
+}
+
 // WithBefore - Overwrites flag checking before action.
 func WithBefore(fnc func(*App) error) Option {
 	return func(app *App) {
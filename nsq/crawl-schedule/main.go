@@ -8,8 +8,10 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	cliflags "github.com/crackcomm/cli-flags"
 	"github.com/crackcomm/cli-nsq"
@@ -56,6 +58,14 @@ func main() {
 			Name:  "callback",
 			Usage: "crawl request callbacks (required)",
 		},
+		&cli.StringFlag{
+			Name:  "on-error",
+			Usage: "crawl request callback for error responses",
+		},
+		&cli.StringFlag{
+			Name:  "proxy",
+			Usage: "crawl request proxy address",
+		},
 		&cli.StringFlag{
 			Name:  "referer",
 			Usage: "crawl request referer",
@@ -69,6 +79,34 @@ func main() {
 			Name:  "timeout",
 			Usage: "request timeout",
 		},
+		&cli.DurationFlag{
+			Name:  "fetch-timeout",
+			Usage: "crawl request fetch timeout overwriting crawler default",
+		},
+		&cli.BoolFlag{
+			Name:  "trace",
+			Usage: "starts a new trace of crawl request",
+		},
+		&cli.BoolFlag{
+			Name:  "sitemap",
+			Usage: "schedules all URLs from sitemap under URL argument",
+		},
+		&cli.BoolFlag{
+			Name:  "sitemap-discover",
+			Usage: "schedules all URLs from sitemaps listed in robots.txt of URL argument site",
+		},
+		&cli.StringFlag{
+			Name:  "sitemap-since",
+			Usage: "skips sitemap entries modified before date (format: 2006-01-02)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "sitemap-allow",
+			Usage: "schedules only sitemap URLs matching regular expression",
+		},
+		&cli.StringSliceFlag{
+			Name:  "sitemap-deny",
+			Usage: "skips sitemap URLs matching regular expression",
+		},
 	}
 	app.Before = func(c *cli.Context) error {
 		if err := cliflags.RequireAll(c, []cli.Flag{
@@ -101,6 +139,9 @@ func main() {
 			Method:    c.String("method"),
 			Referer:   c.String("referer"),
 			Callbacks: c.StringSlice("callback"),
+			OnError:   c.String("on-error"),
+			Proxy:     c.String("proxy"),
+			Timeout:   c.Duration("fetch-timeout"),
 		}
 
 		ctx := context.Background()
@@ -108,6 +149,13 @@ func main() {
 			ctx = metadata.NewContext(ctx, metadata.MD(md))
 		}
 
+		// Start a new trace, requests are its root spans
+		if c.Bool("trace") {
+			trace := crawl.NewTraceContext()
+			ctx = crawl.WithTrace(ctx, trace)
+			glog.Infof("Trace ID: %s", trace.TraceID)
+		}
+
 		if glog.V(3) {
 			body, _ := json.MarshalIndent(request, "", "  ")
 			glog.Infof("Scheduling request: %s", body)
@@ -132,6 +180,11 @@ func main() {
 		// Configure NSQ producer logger
 		q.Producer.SetLogger(log.New(os.Stdout, "[nsq]", 0), nsq.LogLevelError)
 
+		// Schedule requests from sitemaps
+		if c.Bool("sitemap") || c.Bool("sitemap-discover") {
+			return scheduleSitemaps(ctx, c, q, request)
+		}
+
 		// Schedule request
 		if err := q.Schedule(ctx, request); err != nil {
 			return fmt.Errorf("schedule error: %v", err)
@@ -144,6 +197,62 @@ func main() {
 	}
 }
 
+// scheduleSitemaps - Schedules requests for all URLs from sitemaps.
+// Scheduled requests are copies of request with URL of sitemap entry.
+// Sitemaps are fetched without the request context deadline.
+func scheduleSitemaps(ctx context.Context, c *cli.Context, q crawl.Scheduler, request *crawl.Request) (err error) {
+	s := &crawl.Sitemaps{
+		UserAgent: crawl.DefaultHeaders["User-Agent"],
+		Request:   request,
+	}
+	if since := c.String("sitemap-since"); since != "" {
+		if s.Since, err = time.Parse("2006-01-02", since); err != nil {
+			return fmt.Errorf("Sitemap since error: %v", err)
+		}
+	}
+	if s.Allow, err = compileAll(c.StringSlice("sitemap-allow")); err != nil {
+		return fmt.Errorf("Sitemap allow error: %v", err)
+	}
+	if s.Deny, err = compileAll(c.StringSlice("sitemap-deny")); err != nil {
+		return fmt.Errorf("Sitemap deny error: %v", err)
+	}
+
+	sitemaps := []string{request.URL}
+	if c.Bool("sitemap-discover") {
+		if sitemaps, err = s.Discover(context.Background(), request.URL); err != nil {
+			return fmt.Errorf("Sitemap discover error: %v", err)
+		}
+	}
+
+	n, err := s.Schedule(context.Background(), &contextScheduler{ctx: ctx, Scheduler: q}, sitemaps...)
+	glog.Infof("Scheduled %d requests from sitemaps", n)
+	if err != nil {
+		return fmt.Errorf("sitemap error: %v", err)
+	}
+	return nil
+}
+
+// contextScheduler - Schedules requests with request context.
+type contextScheduler struct {
+	crawl.Scheduler
+	ctx context.Context
+}
+
+func (s *contextScheduler) Schedule(_ context.Context, req *crawl.Request) error {
+	return s.Scheduler.Schedule(s.ctx, req)
+}
+
+func compileAll(exprs []string) (list []*regexp.Regexp, err error) {
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, re)
+	}
+	return
+}
+
 func listToForm(list []string) (result url.Values, err error) {
 	result = make(url.Values)
 	for _, keyValue := range list {
@@ -2,25 +2,38 @@ package nsqcrawl
 
 import (
 	"io"
-	"time"
 
-	"google.golang.org/grpc/metadata"
-
-	"github.com/golang/glog"
 	"golang.org/x/net/context"
 
 	"github.com/crackcomm/crawl"
+	"github.com/crackcomm/crawl/envelope"
+	"github.com/crackcomm/crawl/glogger"
 	"github.com/crackcomm/nsqueue/consumer"
 	"github.com/crackcomm/nsqueue/producer"
 )
 
+// Option - Queue option.
+type Option func(*Queue)
+
+// WithLogger - Sets queue logger.
+// Default: glog logger.
+func WithLogger(logger crawl.Logger) Option {
+	return func(queue *Queue) {
+		queue.logger = logger
+	}
+}
+
 // NewQueue - Creates nsq consumer and producer.
-func NewQueue(topic, channel string, maxInFlight int) *Queue {
+func NewQueue(topic, channel string, maxInFlight int, opts ...Option) *Queue {
 	q := &Queue{
 		Consumer: consumer.New(),
 		Producer: producer.New(),
 		channel:  make(chan *nsqJob, maxInFlight+1),
 		topic:    topic,
+		logger:   glogger.New(),
+	}
+	for _, opt := range opts {
+		opt(q)
 	}
 	q.Consumer.Register(topic, channel, maxInFlight, q.nsqHandler)
 	return q
@@ -31,6 +44,7 @@ func NewProducer(topic string) *Queue {
 	return &Queue{
 		Producer: producer.New(),
 		topic:    topic,
+		logger:   glogger.New(),
 	}
 }
 
@@ -41,17 +55,13 @@ type Queue struct {
 
 	topic   string
 	channel chan *nsqJob
+	logger  crawl.Logger
 }
 
 // Schedule - Schedules job in nsq.
 // It will not call job.Done ever.
 func (queue *Queue) Schedule(ctx context.Context, req *crawl.Request) (err error) {
-	md, _ := metadata.FromContext(ctx)
-	r := &Request{Request: req, Metadata: md}
-	if deadline, ok := ctx.Deadline(); ok {
-		r.Deadline = deadline
-	}
-	return queue.Producer.PublishJSON(queue.topic, r)
+	return queue.Producer.PublishJSON(queue.topic, envelope.New(ctx, req))
 }
 
 // Get - Gets job from channel.
@@ -81,44 +91,27 @@ func (queue *Queue) nsqHandler(msg *consumer.Message) {
 	req := new(Request)
 	err := msg.ReadJSON(req)
 	if err != nil {
-		glog.V(3).Infof("nsq json (%s) error: %v", msg.Body, err)
+		queue.logger.Log(crawl.LevelDebug, "nsq json error", crawl.F("body", string(msg.Body)), crawl.F("error", err))
 		msg.GiveUp()
 		return
 	}
 
 	// Check if deadline exceeded
-	if !req.Deadline.IsZero() && time.Now().After(req.Deadline) {
-		glog.V(3).Infof("request deadline exceeded (%s)", msg.Body)
+	if req.Expired() {
+		queue.logger.Log(crawl.LevelDebug, "request deadline exceeded", crawl.F("body", string(msg.Body)))
 		msg.GiveUp()
 		return
 	}
 
-	// Request context
-	ctx := context.Background()
-
-	// Set request deadline
-	if !req.Deadline.IsZero() {
-		ctx, _ = context.WithDeadline(ctx, req.Deadline)
-	}
-
-	// Set nsq message in context
-	ctx = consumer.WithMessage(ctx, msg)
-
-	// Set metadata in context
-	if len(req.Metadata) > 0 {
-		ctx = metadata.NewContext(ctx, req.Metadata)
-	}
+	// Request context with nsq message
+	ctx := req.Context(consumer.WithMessage(context.Background(), msg))
 
 	// Schedule job in memory
 	queue.channel <- &nsqJob{msg: msg, req: req.Request, ctx: ctx}
 }
 
 // Request - Request as it is in NSQ.
-type Request struct {
-	Request  *crawl.Request `json:"request,omitempty"`
-	Deadline time.Time      `json:"deadline,omitempty"`
-	Metadata metadata.MD    `json:"metadata,omitempty"`
-}
+type Request = envelope.Request
 
 type nsqJob struct {
 	msg *consumer.Message
@@ -1,27 +1,21 @@
 package crawl
 
-import (
-	"golang.org/x/net/context"
-	"google.golang.org/grpc/metadata"
-)
+import "golang.org/x/net/context"
 
-var proxyKey = "crawl_proxy"
+// proxyKey - Context key of proxies list.
+type proxyKey struct{}
 
-// WithProxy - Sets proxies in context metadata.
+// WithProxy - Appends proxies to context proxies.
+// Request is executed through one of them.
 func WithProxy(ctx context.Context, addrs ...string) context.Context {
-	if md, ok := metadata.FromContext(ctx); ok {
-		return metadata.NewOutgoingContext(ctx, metadata.MD{
-			proxyKey: append(md[proxyKey], addrs...),
-		})
-	}
-	return metadata.NewOutgoingContext(ctx, metadata.MD{proxyKey: addrs})
+	prev, _ := ProxyFromContext(ctx)
+	list := make([]string, 0, len(prev)+len(addrs))
+	list = append(append(list, prev...), addrs...)
+	return context.WithValue(ctx, proxyKey{}, list)
 }
 
-// ProxyFromContext - Returns proxy from context metadata.
+// ProxyFromContext - Returns proxies from context.
 func ProxyFromContext(ctx context.Context) (addrs []string, ok bool) {
-	md, ok := metadata.FromOutgoingContext(ctx)
-	if ok {
-		addrs = md[proxyKey]
-	}
+	addrs, ok = ctx.Value(proxyKey{}).([]string)
 	return
 }
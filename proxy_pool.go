@@ -0,0 +1,447 @@
+package crawl
+
+import (
+	"container/list"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/proxy"
+)
+
+// ProxyStrategy - Strategy of selecting a proxy from a pool.
+type ProxyStrategy int
+
+const (
+	// ProxyRoundRobin - Proxies are selected in turn.
+	ProxyRoundRobin ProxyStrategy = iota
+	// ProxyRandom - Proxies are selected randomly.
+	ProxyRandom
+	// ProxyLeastFailures - Proxy with the least failures is selected.
+	ProxyLeastFailures
+	// ProxyStickyPerHost - Every host is requested using the same proxy
+	// until it is quarantined.
+	ProxyStickyPerHost
+)
+
+// ProxyBanError - Error returned when response was detected as a ban.
+type ProxyBanError struct {
+	Proxy      string
+	StatusCode int
+}
+
+// Error - Returns proxy ban error message.
+func (err *ProxyBanError) Error() string {
+	return fmt.Sprintf("proxy %s banned (status %d)", err.Proxy, err.StatusCode)
+}
+
+// ProxyStats - Proxy metrics.
+type ProxyStats struct {
+	Proxy     string
+	Requests  int64
+	Successes int64
+	Failures  int64
+	Bans      int64
+	// QuarantinedUntil - Time until proxy is not used, zero if it is healthy.
+	QuarantinedUntil time.Time
+}
+
+// DefaultBanDetector - Detects bans by 403, 407 and 429 status codes.
+func DefaultBanDetector(resp *Response) bool {
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusProxyAuthRequired, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// ProxyPoolOption - Proxy pool option.
+type ProxyPoolOption func(*ProxyPool)
+
+// WithProxyStrategy - Sets proxy selection strategy.
+// Default: ProxyRoundRobin.
+func WithProxyStrategy(strategy ProxyStrategy) ProxyPoolOption {
+	return func(pool *ProxyPool) {
+		pool.strategy = strategy
+	}
+}
+
+// WithProxyQuarantine - Sets duration proxy is not used
+// after it was banned or it failed too many times.
+// Default: 1 minute.
+func WithProxyQuarantine(d time.Duration) ProxyPoolOption {
+	return func(pool *ProxyPool) {
+		pool.quarantine = d
+	}
+}
+
+// WithProxyMaxFailures - Sets number of consecutive failures
+// after which proxy is quarantined.
+// Default: 3.
+func WithProxyMaxFailures(n int) ProxyPoolOption {
+	return func(pool *ProxyPool) {
+		pool.maxFailures = n
+	}
+}
+
+// WithBanDetector - Sets function detecting ban responses.
+// It is called with a parsed response so it can look for ban pages.
+// Nil disables ban detection.
+// Default: DefaultBanDetector.
+func WithBanDetector(detector func(*Response) bool) ProxyPoolOption {
+	return func(pool *ProxyPool) {
+		pool.banned = detector
+	}
+}
+
+// WithProxyTransport - Sets function creating transport for a proxy.
+// Transport is created once per proxy and it is shared by all crawlers
+// using the pool, crawler transport settings are not applied to it.
+// Default: transport with http.DefaultTransport settings.
+func WithProxyTransport(transport func(*url.URL) (*http.Transport, error)) ProxyPoolOption {
+	return func(pool *ProxyPool) {
+		pool.transport = transport
+	}
+}
+
+// WithProxyStickyLimit - Sets maximum number of hosts remembered
+// by ProxyStickyPerHost strategy, least recently used are forgotten.
+// Default: 10000.
+func WithProxyStickyLimit(n int) ProxyPoolOption {
+	return func(pool *ProxyPool) {
+		pool.stickyLimit = n
+	}
+}
+
+// WithProxyLimit - Sets maximum number of request and context proxies
+// tracked in addition to pool proxies. Least recently used are forgotten
+// with their stats and their idle connections are closed.
+// Default: 1000.
+func WithProxyLimit(n int) ProxyPoolOption {
+	return func(pool *ProxyPool) {
+		pool.othersLimit = n
+	}
+}
+
+// ProxyPool - Pool of proxies with cached transports.
+// Proxies health is tracked from requests outcomes.
+// Proxy URL scheme can be http, https or socks5.
+type ProxyPool struct {
+	strategy    ProxyStrategy
+	quarantine  time.Duration
+	maxFailures int
+	banned      func(*Response) bool
+	stickyLimit int
+	othersLimit int
+
+	// transport - creates transport for a proxy
+	transport func(*url.URL) (*http.Transport, error)
+
+	mutex   *sync.Mutex
+	proxies []*proxyState
+	byAddr  map[string]*proxyState
+	// others - request and context proxies by address
+	others *proxyCache
+	next   int
+	// sticky - proxies of hosts selected by ProxyStickyPerHost strategy
+	sticky *proxyCache
+}
+
+// proxyState - Proxy transport and health.
+type proxyState struct {
+	addr      string
+	url       *url.URL
+	transport *http.Transport
+
+	stats ProxyStats
+	// consecutive - number of consecutive failures
+	consecutive int
+}
+
+// NewProxyPool - Creates a new proxy pool.
+func NewProxyPool(addrs []string, opts ...ProxyPoolOption) (pool *ProxyPool, err error) {
+	pool = &ProxyPool{
+		strategy:    ProxyRoundRobin,
+		quarantine:  time.Minute,
+		maxFailures: 3,
+		banned:      DefaultBanDetector,
+		stickyLimit: 10000,
+		othersLimit: 1000,
+		transport:   defaultProxyTransport,
+		mutex:       new(sync.Mutex),
+		byAddr:      make(map[string]*proxyState),
+	}
+	for _, opt := range opts {
+		opt(pool)
+	}
+	pool.others = newProxyCache(pool.othersLimit)
+	pool.sticky = newProxyCache(pool.stickyLimit)
+	for _, addr := range addrs {
+		if _, ok := pool.byAddr[addr]; ok {
+			continue
+		}
+		state, err := newProxyState(addr)
+		if err != nil {
+			return nil, err
+		}
+		pool.byAddr[addr] = state
+		pool.proxies = append(pool.proxies, state)
+	}
+	return
+}
+
+// Stats - Returns metrics of pool proxies followed by tracked
+// request and context proxies.
+func (pool *ProxyPool) Stats() (list []ProxyStats) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	for _, state := range pool.proxies {
+		list = append(list, state.stats)
+	}
+	for e := pool.others.list.Back(); e != nil; e = e.Prev() {
+		list = append(list, e.Value.(*proxyEntry).state.stats)
+	}
+	return
+}
+
+// state - Returns proxy state, request and context proxies
+// are created if they are not tracked. Mutex has to be locked.
+func (pool *ProxyPool) state(addr string) (*proxyState, error) {
+	if state, ok := pool.byAddr[addr]; ok {
+		return state, nil
+	}
+	if state, ok := pool.others.get(addr); ok {
+		return state, nil
+	}
+	state, err := newProxyState(addr)
+	if err != nil {
+		return nil, err
+	}
+	if evicted := pool.others.add(addr, state); evicted != nil && evicted.transport != nil {
+		evicted.transport.CloseIdleConnections()
+	}
+	return state, nil
+}
+
+// newProxyState - Parses proxy address and creates its state.
+func newProxyState(addr string) (*proxyState, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy %s: unsupported scheme %q", addr, u.Scheme)
+	}
+	return &proxyState{addr: addr, url: u, stats: ProxyStats{Proxy: addr}}, nil
+}
+
+// pick - Selects a proxy for host from addrs or from all pool proxies
+// if addrs is empty. Returns nil if there are no proxies.
+// If all proxies are quarantined the one released first is selected.
+func (pool *ProxyPool) pick(host string, addrs []string) (_ *proxyState, err error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	candidates := pool.proxies
+	if len(addrs) > 0 {
+		candidates = make([]*proxyState, len(addrs))
+		for i, addr := range addrs {
+			if candidates[i], err = pool.state(addr); err != nil {
+				return
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	now := time.Now()
+	healthy := make([]*proxyState, 0, len(candidates))
+	for _, state := range candidates {
+		if !now.Before(state.stats.QuarantinedUntil) {
+			healthy = append(healthy, state)
+		}
+	}
+	if len(healthy) == 0 {
+		first := candidates[0]
+		for _, state := range candidates[1:] {
+			if state.stats.QuarantinedUntil.Before(first.stats.QuarantinedUntil) {
+				first = state
+			}
+		}
+		healthy = []*proxyState{first}
+	}
+
+	state := pool.selectProxy(host, healthy)
+	if state.transport == nil {
+		if state.transport, err = pool.transport(state.url); err != nil {
+			return nil, err
+		}
+	}
+	state.stats.Requests++
+	return state, nil
+}
+
+// selectProxy - Selects proxy from healthy ones using pool strategy.
+// Mutex has to be locked.
+func (pool *ProxyPool) selectProxy(host string, healthy []*proxyState) (state *proxyState) {
+	switch pool.strategy {
+	case ProxyRandom:
+		return healthy[rand.Intn(len(healthy))]
+	case ProxyLeastFailures:
+		state = healthy[0]
+		for _, s := range healthy[1:] {
+			if s.stats.Failures+s.stats.Bans < state.stats.Failures+state.stats.Bans {
+				state = s
+			}
+		}
+		return
+	case ProxyStickyPerHost:
+		if state, ok := pool.sticky.get(host); ok {
+			for _, s := range healthy {
+				if s == state {
+					return state
+				}
+			}
+		}
+		state = pool.roundRobin(healthy)
+		pool.sticky.add(host, state)
+		return
+	}
+	return pool.roundRobin(healthy)
+}
+
+func (pool *ProxyPool) roundRobin(healthy []*proxyState) *proxyState {
+	state := healthy[pool.next%len(healthy)]
+	pool.next++
+	return state
+}
+
+// proxyCache - Proxies by key with least recently used eviction.
+type proxyCache struct {
+	// limit - maximum number of entries, zero means no limit
+	limit int
+	// list - entries from the most recently used
+	list  *list.List
+	items map[string]*list.Element
+}
+
+type proxyEntry struct {
+	key   string
+	state *proxyState
+}
+
+func newProxyCache(limit int) *proxyCache {
+	return &proxyCache{
+		limit: limit,
+		list:  list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get - Returns proxy under key and marks it as recently used.
+func (cache *proxyCache) get(key string) (*proxyState, bool) {
+	e, ok := cache.items[key]
+	if !ok {
+		return nil, false
+	}
+	cache.list.MoveToFront(e)
+	return e.Value.(*proxyEntry).state, true
+}
+
+// add - Sets proxy under key and marks it as recently used.
+// Returns evicted proxy if limit was exceeded.
+func (cache *proxyCache) add(key string, state *proxyState) (evicted *proxyState) {
+	if e, ok := cache.items[key]; ok {
+		e.Value.(*proxyEntry).state = state
+		cache.list.MoveToFront(e)
+		return
+	}
+	cache.items[key] = cache.list.PushFront(&proxyEntry{key: key, state: state})
+	if cache.limit > 0 && cache.list.Len() > cache.limit {
+		entry := cache.list.Remove(cache.list.Back()).(*proxyEntry)
+		delete(cache.items, entry.key)
+		return entry.state
+	}
+	return
+}
+
+// defaultProxyTransport - Creates transport for a proxy
+// with http.DefaultTransport settings.
+func defaultProxyTransport(u *url.URL) (*http.Transport, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return proxyTransport(u, dialer, func(dial dialFunc) *http.Transport {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = dial
+		return t
+	})
+}
+
+// dialFunc - Context dialer function.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// proxyTransport - Creates transport using http proxy or socks5 dialer.
+func proxyTransport(u *url.URL, forward *net.Dialer, transport func(dialFunc) *http.Transport) (*http.Transport, error) {
+	if u.Scheme == "http" || u.Scheme == "https" {
+		t := transport(forward.DialContext)
+		t.Proxy = http.ProxyURL(u)
+		return t, nil
+	}
+	dialer, err := proxy.FromURL(u, forward)
+	if err != nil {
+		return nil, err
+	}
+	t := transport(func(_ context.Context, network, addr string) (net.Conn, error) {
+		return dialer.Dial(network, addr)
+	})
+	t.Proxy = nil
+	return t, nil
+}
+
+// success - Records successful request.
+func (pool *ProxyPool) success(state *proxyState) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	state.stats.Successes++
+	state.consecutive = 0
+}
+
+// failure - Records failed request and quarantines proxy
+// after too many consecutive failures.
+func (pool *ProxyPool) failure(state *proxyState) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	state.stats.Failures++
+	state.consecutive++
+	if pool.maxFailures > 0 && state.consecutive >= pool.maxFailures {
+		state.consecutive = 0
+		state.stats.QuarantinedUntil = time.Now().Add(pool.quarantine)
+	}
+}
+
+// ban - Records ban and quarantines proxy.
+func (pool *ProxyPool) ban(state *proxyState) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	state.stats.Bans++
+	state.consecutive = 0
+	state.stats.QuarantinedUntil = time.Now().Add(pool.quarantine)
+}
+
+// check - Records response outcome.
+// Returns *ProxyBanError if response is a ban.
+func (pool *ProxyPool) check(state *proxyState, resp *Response) error {
+	if pool.banned != nil && pool.banned(resp) {
+		pool.ban(state)
+		return &ProxyBanError{Proxy: state.addr, StatusCode: resp.StatusCode}
+	}
+	pool.success(state)
+	return nil
+}
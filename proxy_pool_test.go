@@ -0,0 +1,135 @@
+package crawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// TestProxyPool -
+func TestProxyPool(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<p>%s</p>", r.URL.Host)
+	}))
+	defer good.Close()
+	banned := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer banned.Close()
+
+	pool, err := NewProxyPool([]string{banned.URL, good.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(WithProxyPool(pool))
+
+	// First request goes through banned proxy
+	_, err = c.Execute(context.Background(), &Request{URL: "http://example.com/"})
+	if _, ok := err.(*ProxyBanError); !ok {
+		t.Fatalf("expected proxy ban error, got %v", err)
+	}
+	// Banned proxy is quarantined
+	for i := 0; i < 2; i++ {
+		resp, err := c.Execute(context.Background(), &Request{URL: "http://example.com/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text := Text(resp, "p"); text != "example.com" {
+			t.Errorf("unexpected response %q", text)
+		}
+	}
+
+	stats := pool.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expected stats of 2 proxies, got %d", len(stats))
+	}
+	if s := stats[0]; s.Requests != 1 || s.Bans != 1 || s.QuarantinedUntil.IsZero() {
+		t.Errorf("unexpected banned proxy stats %+v", s)
+	}
+	if s := stats[1]; s.Requests != 2 || s.Successes != 2 || s.Failures != 0 {
+		t.Errorf("unexpected good proxy stats %+v", s)
+	}
+}
+
+// TestProxyPoolSticky -
+func TestProxyPoolSticky(t *testing.T) {
+	pool, err := NewProxyPool([]string{"http://a:1", "http://b:1", "socks5://c:1"}, WithProxyStrategy(ProxyStickyPerHost))
+	if err != nil {
+		t.Fatal(err)
+	}
+	New(WithProxyPool(pool))
+	first, _ := pool.pick("x.com", nil)
+	second, _ := pool.pick("y.com", nil)
+	if first == second {
+		t.Error("expected hosts to use different proxies")
+	}
+	for i := 0; i < 3; i++ {
+		if p, _ := pool.pick("x.com", nil); p != first {
+			t.Errorf("expected sticky proxy %s, got %s", first.addr, p.addr)
+		}
+	}
+	pool.ban(first)
+	if p, _ := pool.pick("x.com", nil); p == first {
+		t.Error("expected quarantined proxy to be replaced")
+	}
+
+	if _, err := NewProxyPool([]string{"ftp://a:1"}); err == nil {
+		t.Error("expected unsupported scheme error")
+	}
+}
+
+// TestProxyPoolLimits -
+func TestProxyPoolLimits(t *testing.T) {
+	pool, err := NewProxyPool([]string{"http://a:1", "http://b:1"}, WithProxyStrategy(ProxyStickyPerHost), WithProxyStickyLimit(1), WithProxyLimit(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.pick("x.com", nil)
+	pool.pick("y.com", nil)
+	if pool.sticky.list.Len() != 1 {
+		t.Errorf("expected 1 sticky host, got %d", pool.sticky.list.Len())
+	}
+	if _, ok := pool.sticky.get("x.com"); ok {
+		t.Error("expected least recently used host to be forgotten")
+	}
+
+	// Pool proxies are never forgotten
+	pool.pick("x.com", []string{"http://c:1"})
+	pool.pick("x.com", []string{"http://d:1"})
+	var addrs []string
+	for _, s := range pool.Stats() {
+		addrs = append(addrs, s.Proxy)
+	}
+	if fmt.Sprint(addrs) != "[http://a:1 http://b:1 http://d:1]" {
+		t.Errorf("unexpected proxies %q", addrs)
+	}
+}
+
+// TestProxyPoolTransport -
+func TestProxyPoolTransport(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer proxy.Close()
+
+	// Pool transport is shared by crawlers using the pool
+	var created int
+	pool, err := NewProxyPool([]string{proxy.URL}, WithProxyTransport(func(u *url.URL) (*http.Transport, error) {
+		created++
+		return &http.Transport{Proxy: http.ProxyURL(u)}, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Crawler{New(WithProxyPool(pool)), New(WithProxyPool(pool), WithConnectTimeout(time.Second))} {
+		if _, err := c.Execute(context.Background(), &Request{URL: "http://example.com/"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected 1 transport, got %d", created)
+	}
+}
@@ -1,6 +1,9 @@
 package crawl
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"golang.org/x/net/context"
@@ -20,3 +23,56 @@ func TestProxyFromContext(t *testing.T) {
 		t.Fail()
 	}
 }
+
+// TestProxyAppend -
+func TestProxyAppend(t *testing.T) {
+	ctx := WithProxy(context.Background(), "a")
+	WithProxy(ctx, "x")
+	addrs, _ := ProxyFromContext(WithProxy(ctx, "b"))
+	if len(addrs) != 2 || addrs[0] != "a" || addrs[1] != "b" {
+		t.Errorf("unexpected proxies %q", addrs)
+	}
+}
+
+// TestRequestProxy -
+func TestRequestProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.String())
+	}))
+	defer proxy.Close()
+
+	c := New(WithProxies("http://127.0.0.1:1"))
+	resp, err := c.Execute(context.Background(), &Request{URL: "http://example.com/a", Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := Text(resp, "body"); text != "http://example.com/a" {
+		t.Errorf("unexpected response %q", text)
+	}
+	if _, err := c.Execute(context.Background(), &Request{URL: "http://example.com/a"}); err == nil {
+		t.Error("expected crawler proxy to be used")
+	}
+}
+
+// TestProxyNoBanDetection -
+func TestProxyNoBanDetection(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+
+	// Ban is not detected when proxy pool is not set explicitly
+	c := New(WithProxies(proxy.URL), WithRetryPolicy(DefaultRetryPolicy))
+	var status int
+	c.Register("error", func(_ context.Context, resp *Response) error {
+		status = resp.StatusCode
+		return nil
+	})
+	_, err := c.Execute(context.Background(), &Request{URL: "http://example.com/a", OnError: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusForbidden {
+		t.Errorf("expected error callback with 403, got %d", status)
+	}
+}
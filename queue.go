@@ -27,3 +27,25 @@ type Queue interface {
 	// Close - Closes the queue.
 	Close() error
 }
+
+// Scheduler - Schedules requests, eg. Crawler or Queue.
+type Scheduler interface {
+	Schedule(context.Context, *Request) error
+}
+
+// IdleQueue - Queue which can report when all jobs are done.
+// Crawler stops when the queue is idle.
+type IdleQueue interface {
+	Queue
+
+	// Idle - Returns true if queue is empty and all jobs are done.
+	Idle() bool
+}
+
+// LenQueue - Queue which can report its length.
+type LenQueue interface {
+	Queue
+
+	// Len - Returns number of requests waiting in the queue.
+	Len() int
+}
@@ -3,6 +3,7 @@ package crawl
 import (
 	"io"
 	"sync"
+	"sync/atomic"
 
 	"golang.org/x/net/context"
 )
@@ -19,6 +20,10 @@ func NewQueue(capacity int) Queue {
 }
 
 type memQueue struct {
+	// pending - number of scheduled jobs which are not done
+	// It is first to be 64-bit aligned for atomic operations.
+	pending int64
+
 	writeChan chan Job
 	readChan  chan Job
 	mutex     *sync.RWMutex
@@ -41,10 +46,19 @@ func (queue *memQueue) Schedule(ctx context.Context, r *Request) error {
 	if queue.writeChan == nil {
 		return io.ErrClosedPipe
 	}
-	queue.writeChan <- &memJob{ctx: ctx, req: r}
+	atomic.AddInt64(&queue.pending, 1)
+	queue.writeChan <- &memJob{ctx: ctx, req: r, pending: &queue.pending}
 	return nil
 }
 
+func (queue *memQueue) Len() int {
+	return len(queue.readChan)
+}
+
+func (queue *memQueue) Idle() bool {
+	return atomic.LoadInt64(&queue.pending) == 0
+}
+
 func (queue *memQueue) Close() (err error) {
 	queue.mutex.Lock()
 	defer queue.mutex.Unlock()
@@ -59,8 +73,9 @@ func (queue *memQueue) Close() (err error) {
 
 // memJob - Structure to make Request+Context a Job interface.
 type memJob struct {
-	req *Request
-	ctx context.Context
+	req     *Request
+	ctx     context.Context
+	pending *int64
 }
 
 func (job *memJob) Context() context.Context {
@@ -72,4 +87,7 @@ func (job *memJob) Request() *Request {
 }
 
 func (job *memJob) Done() {
+	if job.pending != nil {
+		atomic.AddInt64(job.pending, -1)
+	}
 }
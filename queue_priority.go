@@ -0,0 +1,130 @@
+package crawl
+
+import (
+	"container/heap"
+	"io"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// NewPriorityQueue - Makes a new in-memory priority queue.
+// Requests with higher priority are returned first, requests
+// with equal priority are returned in order they were scheduled.
+// Schedule blocks when queue reaches capacity.
+func NewPriorityQueue(capacity int) Queue {
+	mutex := new(sync.Mutex)
+	return &priorityQueue{
+		mutex:    mutex,
+		cond:     sync.NewCond(mutex),
+		capacity: capacity,
+	}
+}
+
+type priorityQueue struct {
+	mutex    *sync.Mutex
+	cond     *sync.Cond
+	jobs     priorityJobs
+	capacity int
+	seq      uint64
+	pending  int
+	closed   bool
+}
+
+func (queue *priorityQueue) Get() (Job, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for len(queue.jobs) == 0 {
+		if queue.closed {
+			return nil, io.EOF
+		}
+		queue.cond.Wait()
+	}
+	job := heap.Pop(&queue.jobs).(*priorityJob)
+	queue.cond.Broadcast()
+	return job, nil
+}
+
+func (queue *priorityQueue) Schedule(ctx context.Context, r *Request) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for !queue.closed && queue.capacity > 0 && len(queue.jobs) >= queue.capacity {
+		queue.cond.Wait()
+	}
+	if queue.closed {
+		return io.ErrClosedPipe
+	}
+	queue.seq++
+	queue.pending++
+	heap.Push(&queue.jobs, &priorityJob{queue: queue, ctx: ctx, req: r, seq: queue.seq})
+	queue.cond.Broadcast()
+	return nil
+}
+
+func (queue *priorityQueue) Len() int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return len(queue.jobs)
+}
+
+func (queue *priorityQueue) Idle() bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return queue.pending == 0
+}
+
+// Close - Closes the queue.
+// Remaining requests can be still received using Get.
+func (queue *priorityQueue) Close() error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.closed = true
+	queue.cond.Broadcast()
+	return nil
+}
+
+// priorityJob - Job in a priority queue.
+type priorityJob struct {
+	queue *priorityQueue
+	req   *Request
+	ctx   context.Context
+	seq   uint64
+}
+
+func (job *priorityJob) Context() context.Context {
+	return job.ctx
+}
+
+func (job *priorityJob) Request() *Request {
+	return job.req
+}
+
+func (job *priorityJob) Done() {
+	job.queue.mutex.Lock()
+	defer job.queue.mutex.Unlock()
+	job.queue.pending--
+}
+
+// priorityJobs - Implements heap.Interface.
+type priorityJobs []*priorityJob
+
+func (jobs priorityJobs) Len() int { return len(jobs) }
+
+func (jobs priorityJobs) Less(i, j int) bool {
+	if jobs[i].req.Priority != jobs[j].req.Priority {
+		return jobs[i].req.Priority > jobs[j].req.Priority
+	}
+	return jobs[i].seq < jobs[j].seq
+}
+
+func (jobs priorityJobs) Swap(i, j int) { jobs[i], jobs[j] = jobs[j], jobs[i] }
+
+func (jobs *priorityJobs) Push(x interface{}) { *jobs = append(*jobs, x.(*priorityJob)) }
+
+func (jobs *priorityJobs) Pop() interface{} {
+	old := *jobs
+	job := old[len(old)-1]
+	old[len(old)-1] = nil
+	*jobs = old[:len(old)-1]
+	return job
+}
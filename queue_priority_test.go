@@ -0,0 +1,34 @@
+package crawl
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// TestPriorityQueue -
+func TestPriorityQueue(t *testing.T) {
+	queue := NewPriorityQueue(10)
+	for _, req := range []*Request{
+		{URL: "detail-1"},
+		{URL: "list", Priority: 10},
+		{URL: "detail-2"},
+		{URL: "sitemap", Priority: 20},
+	} {
+		queue.Schedule(context.Background(), req)
+	}
+	queue.Close()
+	for _, expected := range []string{"sitemap", "list", "detail-1", "detail-2"} {
+		job, err := queue.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Request().URL != expected {
+			t.Errorf("expected %s, got %s", expected, job.Request().URL)
+		}
+		job.Done()
+	}
+	if !queue.(IdleQueue).Idle() {
+		t.Error("expected queue to be idle")
+	}
+}
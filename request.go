@@ -1,11 +1,15 @@
 package crawl
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // Request - HTTP Request.
@@ -26,9 +30,105 @@ type Request struct {
 	// Header - Header values.
 	Header map[string]string `json:"header,omitempty"`
 	// Raw - when set to false, it means we expect HTML response
+	// Same as setting Format to FormatRaw.
 	Raw bool `json:"raw,omitempty"`
+	// Format - Expected response format.
+	// When empty response is parsed as HTML, when it is FormatAuto
+	// format is detected from response Content-Type.
+	Format Format `json:"format,omitempty"`
+	// Stream - When set to true response body is not read before handlers
+	// and it can be read using Response Reader(). It implies Raw.
+	Stream bool `json:"stream,omitempty"`
+	// Charset - Response body charset overriding detected one.
+	Charset string `json:"charset,omitempty"`
+	// MaxBodySize - Maximum size of response body in bytes.
+	// Zero means crawler default is used.
+	MaxBodySize int64 `json:"max_body_size,omitempty"`
+	// Proxy - Proxy address request is executed through.
+	// It takes precedence over context and crawler proxies.
+	Proxy string `json:"proxy,omitempty"`
+	// Timeout - Total timeout of request including reading response body.
+	// Zero means crawler default is used. In JSON it is a duration
+	// string, eg. "1m30s", integer nanoseconds are accepted too.
+	Timeout time.Duration `json:"timeout,omitempty"`
 	// Callbacks - Crawl callback list.
 	Callbacks []string `json:"callbacks,omitempty"`
+	// OnError - Callback executed instead of Callbacks
+	// for responses with 4xx and 5xx status code.
+	OnError string `json:"on_error,omitempty"`
+	// AllowDuplicate - When set to true request is scheduled
+	// even if it was already seen by crawler deduplicator.
+	AllowDuplicate bool `json:"allow_duplicate,omitempty"`
+	// Attempt - Number of previous attempts of this request.
+	// It is incremented when request is retried.
+	Attempt int `json:"attempt,omitempty"`
+	// NotBefore - Time before which queued request is not executed.
+	// It is set to the end of backoff when request is retried.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// Priority - Request priority, higher is fetched first.
+	// It is respected only by priority queues.
+	Priority int `json:"priority,omitempty"`
+	// Trace - Trace context, request span is its child.
+	// It is set when request is scheduled from a traced handler.
+	Trace *TraceContext `json:"trace,omitempty"`
+	// Depth - Crawl depth of request. It is set when request is scheduled
+	// from a handler with referer set to handled response URL.
+	Depth int `json:"depth,omitempty"`
+}
+
+// Format - Expected response format.
+type Format string
+
+const (
+	// FormatHTML - Response is parsed as HTML.
+	FormatHTML Format = "html"
+	// FormatJSON - Response is parsed as JSON.
+	FormatJSON Format = "json"
+	// FormatXML - Response is checked to be a well-formed XML.
+	FormatXML Format = "xml"
+	// FormatRaw - Response is not parsed.
+	FormatRaw Format = "raw"
+	// FormatAuto - Response format is detected from Content-Type,
+	// JSON and XML media types are detected, otherwise it is HTML.
+	FormatAuto Format = "auto"
+)
+
+// jsonRequest - Request without JSON methods.
+type jsonRequest Request
+
+// MarshalJSON - Marshals request with Timeout as a duration string.
+func (req Request) MarshalJSON() ([]byte, error) {
+	v := struct {
+		*jsonRequest
+		Timeout string `json:"timeout,omitempty"`
+	}{jsonRequest: (*jsonRequest)(&req)}
+	if req.Timeout != 0 {
+		v.Timeout = req.Timeout.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON - Unmarshals request with Timeout as a duration string
+// or integer nanoseconds.
+func (req *Request) UnmarshalJSON(body []byte) (err error) {
+	v := struct {
+		*jsonRequest
+		Timeout json.RawMessage `json:"timeout,omitempty"`
+	}{jsonRequest: (*jsonRequest)(req)}
+	if err = json.Unmarshal(body, &v); err != nil || len(v.Timeout) == 0 {
+		return
+	}
+	var timeout string
+	if err = json.Unmarshal(v.Timeout, &timeout); err != nil {
+		var ns int64
+		if json.Unmarshal(v.Timeout, &ns) != nil {
+			return fmt.Errorf("invalid request timeout %s", v.Timeout)
+		}
+		req.Timeout = time.Duration(ns)
+		return nil
+	}
+	req.Timeout, err = time.ParseDuration(timeout)
+	return
 }
 
 // Callbacks - Helper for creating list of strings (callback names).
@@ -128,3 +228,31 @@ func (req *Request) GetMethod() string {
 func (req *Request) String() string {
 	return fmt.Sprintf("%s %s", req.GetMethod(), req.URL)
 }
+
+// Fingerprint - Returns request fingerprint.
+// Fingerprint is a hash of canonical form of request: method,
+// resolved URL without fragment, sorted query and form body.
+func (req *Request) Fingerprint() (_ string, err error) {
+	u, err := req.ParseURL()
+	if err != nil {
+		return
+	}
+	query := req.Query
+	if query == nil {
+		query = u.Query()
+	}
+	canonical := url.URL{
+		Scheme:   strings.ToLower(u.Scheme),
+		Host:     strings.ToLower(u.Host),
+		Path:     u.Path,
+		RawPath:  u.RawPath,
+		RawQuery: query.Encode(),
+	}
+	method := req.GetMethod()
+	if req.Method == "" && req.Form != nil {
+		method = "POST"
+	}
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %s\n%s", method, canonical.String(), req.Form.Encode())
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
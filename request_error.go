@@ -1,8 +1,21 @@
 package crawl
 
-import "fmt"
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+)
 
 // RequestError - Crawl error.
+// Errors of requests executed from the queue are sent to Errors() as
+// RequestError wrapping one of typed errors, which can be matched
+// using errors.As, eg. FetchError, StatusError or HandlerError.
 type RequestError struct {
 	*Request
 	Err error
@@ -12,3 +25,226 @@ type RequestError struct {
 func (err *RequestError) Error() string {
 	return fmt.Sprintf("%s: %v", err.Request.String(), err.Err)
 }
+
+// Unwrap - Returns underlying error.
+func (err *RequestError) Unwrap() error {
+	return err.Err
+}
+
+// ErrorInfo - Details of request which failed, embedded in typed errors.
+type ErrorInfo struct {
+	// Request - Originating request, nil for errors of queue reads.
+	Request *Request
+	// Attempt - Number of previous attempts of request.
+	Attempt int
+	// Start - Time when request execution started.
+	Start time.Time
+	// Time - Time when error occurred.
+	Time time.Time
+}
+
+// newErrorInfo - Creates error info of request execution started at start.
+func newErrorInfo(req *Request, start time.Time) ErrorInfo {
+	info := ErrorInfo{Request: req, Start: start, Time: time.Now()}
+	if req != nil {
+		info.Attempt = req.Attempt
+	}
+	return info
+}
+
+// FetchPhase - Phase of fetching in which request failed.
+type FetchPhase string
+
+const (
+	// PhaseRequest - Constructing http request, eg. invalid URL.
+	PhaseRequest FetchPhase = "request"
+	// PhaseDNS - Resolving host name.
+	PhaseDNS FetchPhase = "dns"
+	// PhaseDial - Connecting to host or proxy.
+	PhaseDial FetchPhase = "dial"
+	// PhaseTLS - TLS handshake, including certificate verification.
+	PhaseTLS FetchPhase = "tls"
+	// PhaseTimeout - Request timeout or context deadline was exceeded.
+	PhaseTimeout FetchPhase = "timeout"
+	// PhaseProtocol - Writing request or reading response headers.
+	PhaseProtocol FetchPhase = "protocol"
+	// PhaseBody - Reading response body.
+	PhaseBody FetchPhase = "body"
+)
+
+// FetchError - Error returned when request could not be fetched.
+type FetchError struct {
+	ErrorInfo
+	Phase FetchPhase
+	Err   error
+}
+
+// Error - Returns fetch error message.
+func (err *FetchError) Error() string {
+	return fmt.Sprintf("fetch error (%s): %v", err.Phase, err.Err)
+}
+
+// Unwrap - Returns underlying error.
+func (err *FetchError) Unwrap() error {
+	return err.Err
+}
+
+// Timeout - Returns true if fetch timed out.
+func (err *FetchError) Timeout() bool {
+	return err.Phase == PhaseTimeout
+}
+
+// fetchPhase - Returns phase of fetching in which error occurred.
+// Timeouts take precedence over a phase they occurred in.
+func fetchPhase(err error) FetchPhase {
+	var (
+		netErr    net.Error
+		dnsErr    *net.DNSError
+		opErr     *net.OpError
+		verifyErr *tls.CertificateVerificationError
+		recordErr tls.RecordHeaderError
+		alertErr  tls.AlertError
+		authErr   x509.UnknownAuthorityError
+		hostErr   x509.HostnameError
+		certErr   x509.CertificateInvalidError
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return PhaseTimeout
+	case errors.As(err, &dnsErr):
+		return PhaseDNS
+	case errors.As(err, &verifyErr),
+		errors.As(err, &recordErr),
+		errors.As(err, &alertErr),
+		errors.As(err, &authErr),
+		errors.As(err, &hostErr),
+		errors.As(err, &certErr):
+		return PhaseTLS
+	case errors.As(err, &opErr) && opErr.Op == "dial",
+		errors.As(err, &opErr) && opErr.Op == "proxyconnect":
+		return PhaseDial
+	}
+	return PhaseProtocol
+}
+
+// StatusError - Error returned when response has unexpected status code,
+// eg. retryable status when crawler has a retry policy.
+type StatusError struct {
+	ErrorInfo
+	StatusCode int
+	Status     string
+	// Response - Received response, its body is already closed.
+	Response *http.Response
+}
+
+// Error - Returns status error message.
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status: %s", err.Status)
+}
+
+// ParseError - Error returned when response could not be parsed
+// in expected format.
+type ParseError struct {
+	ErrorInfo
+	Format Format
+	Err    error
+}
+
+// Error - Returns parse error message.
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("parse %s error: %v", err.Format, err.Err)
+}
+
+// Unwrap - Returns underlying error.
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// HandlerError - Error returned by a handler.
+type HandlerError struct {
+	ErrorInfo
+	// Callback - Name of callback handler was executed for.
+	Callback string
+	// Index - Index of handler registered under callback name
+	// or glob pattern matching it.
+	Index int
+	Err   error
+}
+
+// Error - Returns handler error message.
+func (err *HandlerError) Error() string {
+	return fmt.Sprintf("handler %s[%d] error: %v", err.Callback, err.Index, err.Err)
+}
+
+// Unwrap - Returns underlying error.
+func (err *HandlerError) Unwrap() error {
+	return err.Err
+}
+
+// MiddlewareError - Error returned by a request or response middleware.
+type MiddlewareError struct {
+	ErrorInfo
+	// Index - Index of middleware in registration order.
+	Index int
+	// Response - True if error was returned by response middleware.
+	Response bool
+	Err      error
+}
+
+// Error - Returns middleware error message.
+func (err *MiddlewareError) Error() string {
+	kind := "request"
+	if err.Response {
+		kind = "response"
+	}
+	return fmt.Sprintf("%s middleware %d error: %v", kind, err.Index, err.Err)
+}
+
+// Unwrap - Returns underlying error.
+func (err *MiddlewareError) Unwrap() error {
+	return err.Err
+}
+
+// QueueError - Error returned by the queue.
+type QueueError struct {
+	ErrorInfo
+	// Op - Queue operation, "get" or "schedule".
+	Op  string
+	Err error
+}
+
+// Error - Returns queue error message.
+func (err *QueueError) Error() string {
+	return fmt.Sprintf("queue %s error: %v", err.Op, err.Err)
+}
+
+// Unwrap - Returns underlying error.
+func (err *QueueError) Unwrap() error {
+	return err.Err
+}
+
+// PanicError - Error of a job which panicked, eg. in a handler.
+// Jobs which panicked are not retried.
+type PanicError struct {
+	ErrorInfo
+	// Value - Value passed to panic.
+	Value interface{}
+	// Stack - Stack trace of panicking goroutine.
+	Stack []byte
+}
+
+// Error - Returns panic error message.
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", err.Value)
+}
+
+// BodySizeError - Error returned when response body exceeds maximum size.
+type BodySizeError struct {
+	Limit int64
+}
+
+// Error - Returns body size error message.
+func (err *BodySizeError) Error() string {
+	return fmt.Sprintf("response body exceeds maximum size of %d bytes", err.Limit)
+}
@@ -0,0 +1,100 @@
+package crawl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// TestTypedErrors -
+func TestTypedErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+
+	failed := errors.New("failed")
+	c := New(WithRetryPolicy(&RetryPolicy{MaxAttempts: 2}))
+	c.Register("page", func(context.Context, *Response) error { return nil })
+	c.Register("page", func(context.Context, *Response) error { return failed })
+	ctx := context.Background()
+
+	_, err := c.Execute(ctx, &Request{URL: ts.URL, Callbacks: Callbacks("page"), Attempt: 1})
+	var handlerErr *HandlerError
+	if !errors.As(err, &handlerErr) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+	if handlerErr.Callback != "page" || handlerErr.Index != 1 || handlerErr.Attempt != 1 || handlerErr.Request.URL != ts.URL {
+		t.Errorf("unexpected handler error %+v", handlerErr)
+	}
+	if !errors.Is(err, failed) || handlerErr.Time.Before(handlerErr.Start) {
+		t.Errorf("unexpected handler error %+v", handlerErr)
+	}
+
+	_, err = c.Execute(ctx, &Request{URL: ts.URL + "/error"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status error, got %v", err)
+	}
+	if !IsRetryable(err) {
+		t.Error("expected status error to be retryable")
+	}
+
+	_, err = c.Execute(ctx, &Request{URL: ts.URL, Format: FormatJSON})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Format != FormatJSON {
+		t.Errorf("expected parse error, got %v", err)
+	}
+
+	c.Middleware(func(context.Context, *Request, *http.Request) error { return failed })
+	_, err = c.Execute(ctx, &Request{URL: ts.URL})
+	var middlewareErr *MiddlewareError
+	if !errors.As(err, &middlewareErr) || middlewareErr.Index != 0 || middlewareErr.Response {
+		t.Errorf("expected request middleware error, got %v", err)
+	}
+}
+
+// TestFetchError -
+func TestFetchError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	c := New()
+	_, err := c.Execute(context.Background(), &Request{URL: ts.URL, Timeout: 10 * time.Millisecond})
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.Phase != PhaseTimeout {
+		t.Errorf("expected timeout fetch error, got %v", err)
+	}
+	if !IsRetryable(&RequestError{Request: &Request{}, Err: err}) {
+		t.Error("expected timeout to be retryable")
+	}
+
+	// Connection to closed server is refused
+	url := ts.URL
+	ts.Close()
+	_, err = c.Execute(context.Background(), &Request{URL: url})
+	if !errors.As(err, &fetchErr) || fetchErr.Phase != PhaseDial {
+		t.Errorf("expected dial fetch error, got %v", err)
+	}
+}
+
+// TestQueueError -
+func TestQueueError(t *testing.T) {
+	c := New()
+	c.Close()
+	err := c.Schedule(context.Background(), &Request{URL: "http://localhost"})
+	var queueErr *QueueError
+	if !errors.As(err, &queueErr) || queueErr.Op != "schedule" || queueErr.Request == nil {
+		t.Errorf("expected queue error, got %v", err)
+	}
+}
@@ -2,11 +2,22 @@ package crawl
 
 import (
 	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
 )
 
 // Response - Crawl http response.
@@ -17,18 +28,187 @@ type Response struct {
 	*http.Response
 	doc  *goquery.Document
 	body []byte
+
+	// maxBodySize - Maximum body size, zero means no limit.
+	maxBodySize int64
+	// reader - Body reader, limited if maxBodySize is set.
+	reader io.Reader
+	// bytesRead - Number of body bytes read.
+	bytesRead int64
+	// charset - Detected body charset name.
+	charset  string
+	encoding encoding.Encoding
+	// json - Decoded JSON body.
+	json interface{}
+	// fetchTime - Time when response was received.
+	fetchTime time.Time
+}
+
+// Format - Returns expected response format.
+// When request Format is empty it is HTML, so HTML document is always
+// available to handlers unless other format is requested explicitly.
+// When it is FormatAuto it is detected from response Content-Type.
+func (r *Response) Format() Format {
+	if r.Request.Raw || r.Request.Stream {
+		return FormatRaw
+	}
+	switch r.Request.Format {
+	case "":
+		return FormatHTML
+	case FormatAuto:
+		return contentTypeFormat(r.Response.Header.Get("Content-Type"))
+	}
+	return r.Request.Format
+}
+
+// contentTypeFormat - Returns format of Content-Type.
+// JSON and XML media types are detected, HTML is returned otherwise.
+func contentTypeFormat(contentType string) Format {
+	mediatype, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediatype == "application/json" || strings.HasSuffix(mediatype, "+json"):
+		return FormatJSON
+	case mediatype == "application/xhtml+xml":
+		return FormatHTML
+	case strings.HasSuffix(mediatype, "/xml") || strings.HasSuffix(mediatype, "+xml"):
+		return FormatXML
+	}
+	return FormatHTML
+}
+
+// parse - Parses response in expected format.
+func (r *Response) parse() error {
+	switch r.Format() {
+	case FormatHTML:
+		return r.ParseHTML()
+	case FormatJSON:
+		return r.ParseJSON()
+	case FormatXML:
+		return r.ParseXML()
+	}
+	return nil
 }
 
 // ParseHTML - Reads response body and parses it as HTML.
+// Body is transcoded to UTF-8 from detected charset.
 func (r *Response) ParseHTML() (err error) {
 	body, err := r.Bytes()
 	if err != nil {
 		return
 	}
+	if err = r.detectCharset(); err != nil {
+		return
+	}
+	if r.charset != "utf-8" {
+		if body, err = r.encoding.NewDecoder().Bytes(body); err != nil {
+			return
+		}
+	}
 	r.doc, err = goquery.NewDocumentFromReader(bytes.NewBuffer(body))
 	return
 }
 
+// ParseJSON - Reads response body and decodes it as JSON.
+// Decoded value can be accessed using JSONPath().
+func (r *Response) ParseJSON() (err error) {
+	if r.json != nil {
+		return
+	}
+	return r.JSON(&r.json)
+}
+
+// ParseXML - Reads response body and checks if it is a well-formed XML.
+func (r *Response) ParseXML() (err error) {
+	body, err := r.Bytes()
+	if err != nil {
+		return
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	root := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			root = true
+		}
+	}
+	if !root {
+		return errors.New("xml root element not found")
+	}
+	return
+}
+
+// JSON - Reads response body and decodes it as JSON into v.
+func (r *Response) JSON(v interface{}) (err error) {
+	body, err := r.Bytes()
+	if err != nil {
+		return
+	}
+	return json.Unmarshal(body, v)
+}
+
+// XML - Reads response body and decodes it as XML into v.
+func (r *Response) XML(v interface{}) (err error) {
+	body, err := r.Bytes()
+	if err != nil {
+		return
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder.Decode(v)
+}
+
+// JSONPath - Looks up a value in decoded JSON body.
+// See JSONPath function for path syntax.
+func (r *Response) JSONPath(path string) (interface{}, bool) {
+	if err := r.ParseJSON(); err != nil {
+		return nil, false
+	}
+	return JSONPath(r.json, path)
+}
+
+// Charset - Returns response body charset name.
+// It is request Charset if set, otherwise it is detected from BOM,
+// Content-Type header and <meta> tag. If none was found it is "utf-8"
+// when whole body is valid UTF-8 and "windows-1252" otherwise.
+// Returns empty string if body could not be read.
+func (r *Response) Charset() string {
+	if err := r.detectCharset(); err != nil {
+		return ""
+	}
+	return r.charset
+}
+
+// detectCharset - Detects response body charset.
+func (r *Response) detectCharset() (err error) {
+	if r.encoding != nil {
+		return
+	}
+	if r.Request != nil && r.Request.Charset != "" {
+		r.encoding, r.charset = charset.Lookup(r.Request.Charset)
+		if r.encoding == nil {
+			return fmt.Errorf("unknown charset %q", r.Request.Charset)
+		}
+		return
+	}
+	body, err := r.Bytes()
+	if err != nil {
+		return
+	}
+	var certain bool
+	r.encoding, r.charset, certain = charset.DetermineEncoding(body, r.Response.Header.Get("Content-Type"))
+	// Only beginning of the body is sniffed, UTF-8 text can follow ASCII
+	if !certain && r.charset != "utf-8" && utf8.Valid(body) {
+		r.encoding, r.charset = charset.Lookup("utf-8")
+	}
+	return
+}
+
 // Bytes - Reads response body and returns byte array.
 func (r *Response) Bytes() (body []byte, err error) {
 	if r.body == nil {
@@ -37,6 +217,21 @@ func (r *Response) Bytes() (body []byte, err error) {
 	return r.body, err
 }
 
+// Reader - Returns response body reader.
+// If body was not read yet, it can be read only once.
+// Read returns *BodySizeError when body exceeds maximum size.
+func (r *Response) Reader() io.Reader {
+	if r.body != nil {
+		return bytes.NewReader(r.body)
+	}
+	return r.bodyReader()
+}
+
+// FetchTime - Returns time when response was received.
+func (r *Response) FetchTime() time.Time {
+	return r.fetchTime
+}
+
 // Status - Gets response status.
 func (r *Response) Status() string {
 	return r.Response.Status
@@ -53,7 +248,11 @@ func (r *Response) Query() *goquery.Document {
 }
 
 // Find - Short for: r.Query().Find(selector).
+// Returns empty selection if response was not parsed as HTML.
 func (r *Response) Find(selector string) *goquery.Selection {
+	if r.doc == nil {
+		return new(goquery.Selection)
+	}
 	return r.doc.Find(selector)
 }
 
@@ -71,6 +270,52 @@ func (r *Response) readBody() (err error) {
 		return
 	}
 	defer r.Response.Body.Close()
-	r.body, err = ioutil.ReadAll(r.Response.Body)
+	r.body, err = ioutil.ReadAll(r.bodyReader())
+	return
+}
+
+// bodyReader - Returns response body reader limited to max body size.
+func (r *Response) bodyReader() io.Reader {
+	if r.reader == nil {
+		r.reader = &countingReader{r: r.Response.Body, n: &r.bytesRead}
+		if r.maxBodySize > 0 {
+			r.reader = &limitedReader{r: r.reader, remaining: r.maxBodySize, limit: r.maxBodySize}
+		}
+	}
+	return r.reader
+}
+
+// limitedReader - Reader returning *BodySizeError when limit is exceeded.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedReader) Read(p []byte) (n int, err error) {
+	if l.remaining < 0 {
+		return 0, &BodySizeError{Limit: l.limit}
+	}
+	// Read one byte more than remaining to detect exceeding
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err = l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n - 1, &BodySizeError{Limit: l.limit}
+	}
+	return
+}
+
+// countingReader - Reader counting read bytes.
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	*c.n += int64(n)
 	return
 }
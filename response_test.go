@@ -0,0 +1,160 @@
+package crawl
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// TestMaxBodySize -
+func TestMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer ts.Close()
+
+	c := New(WithMaxBodySize(10))
+	_, err := c.Execute(context.Background(), &Request{URL: ts.URL})
+	var sizeErr *BodySizeError
+	if !errors.As(err, &sizeErr) {
+		t.Errorf("expected body size error, got %v", err)
+	}
+
+	var body []byte
+	c.Register("stream", func(_ context.Context, resp *Response) (err error) {
+		body, err = ioutil.ReadAll(resp.Reader())
+		return
+	})
+	_, err = c.Execute(context.Background(), &Request{URL: ts.URL, Stream: true, MaxBodySize: 100, Callbacks: Callbacks("stream")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 100 {
+		t.Errorf("expected 100 bytes, got %d", len(body))
+	}
+}
+
+// TestCharset -
+func TestCharset(t *testing.T) {
+	// "Zażółć" in ISO-8859-2
+	body := []byte("<html><head><meta charset=\"iso-8859-2\"></head><body>Za\xbf\xf3\xb3\xe6</body></html>")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	c := New()
+	resp, err := c.Execute(context.Background(), &Request{URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Charset() != "iso-8859-2" {
+		t.Errorf("expected iso-8859-2 charset, got %q", resp.Charset())
+	}
+	if text := Text(resp, "body"); text != "Zażółć" {
+		t.Errorf("unexpected text %q", text)
+	}
+
+	resp, err = c.Execute(context.Background(), &Request{URL: ts.URL, Charset: "windows-1250"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Charset() != "windows-1250" {
+		t.Errorf("expected overridden charset, got %q", resp.Charset())
+	}
+}
+
+// TestCharsetUndeclared -
+func TestCharsetUndeclared(t *testing.T) {
+	// UTF-8 text follows more than 1024 bytes of ASCII
+	body := "<html><body><p>" + strings.Repeat("a", 2048) + "</p><p id=\"text\">Zażółć</p></body></html>"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	resp, err := New().Execute(context.Background(), &Request{URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Charset() != "utf-8" {
+		t.Errorf("expected utf-8 charset, got %q", resp.Charset())
+	}
+	if text := Text(resp, "#text"); text != "Zażółć" {
+		t.Errorf("unexpected text %q", text)
+	}
+}
+
+// TestResponseFormat -
+func TestResponseFormat(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"title": "movie"}`))
+	}))
+	defer ts.Close()
+
+	resp, err := New().Execute(context.Background(), &Request{URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Format() != FormatHTML {
+		t.Errorf("expected default html format, got %q", resp.Format())
+	}
+	if title, _ := resp.JSONPath("title"); title != "movie" {
+		t.Errorf("unexpected title %v", title)
+	}
+	// HTML document is available to existing handlers of JSON endpoints
+	if text := Text(resp, "body"); text != `{"title": "movie"}` {
+		t.Errorf("unexpected body text %q", text)
+	}
+
+	resp, err = New().Execute(context.Background(), &Request{URL: ts.URL, Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := Text(resp, "title"); text != "" || resp.Find("title").Length() != 0 {
+		t.Errorf("expected empty selection of json response, got %q", text)
+	}
+
+	_, err = New().Execute(context.Background(), &Request{URL: ts.URL, Format: FormatXML})
+	if err == nil {
+		t.Error("expected xml parse error")
+	}
+
+	resp, err = New().Execute(context.Background(), &Request{URL: ts.URL, Format: FormatAuto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Format() != FormatJSON || resp.Find("body").Length() != 0 {
+		t.Errorf("expected json format detected from content type, got %q", resp.Format())
+	}
+	if title, _ := resp.JSONPath("title"); title != "movie" {
+		t.Errorf("unexpected title %v", title)
+	}
+}
+
+// TestContentTypeFormat -
+func TestContentTypeFormat(t *testing.T) {
+	for contentType, format := range map[string]Format{
+		"application/json; charset=utf-8": FormatJSON,
+		"application/ld+json":             FormatJSON,
+		"application/xml":                 FormatXML,
+		"text/xml; charset=iso-8859-2":    FormatXML,
+		"application/atom+xml":            FormatXML,
+		"application/xhtml+xml":           FormatHTML,
+		"text/html":                       FormatHTML,
+		"":                                FormatHTML,
+	} {
+		if f := contentTypeFormat(contentType); f != format {
+			t.Errorf("%q: expected %s, got %s", contentType, format, f)
+		}
+	}
+}
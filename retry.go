@@ -0,0 +1,85 @@
+package crawl
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy - Failed requests retry policy.
+// Retried requests are scheduled in the queue again with NotBefore
+// set to the end of backoff, workers wait for it before execution.
+type RetryPolicy struct {
+	// MaxAttempts - Maximum number of attempts including the first one.
+	MaxAttempts int
+	// MinBackoff - Backoff before first retry, doubled on every attempt.
+	MinBackoff time.Duration
+	// MaxBackoff - Maximum backoff duration.
+	MaxBackoff time.Duration
+	// Retryable - Checks if error is retryable.
+	// Default: IsRetryable.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy - Default retry policy.
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  time.Second,
+	MaxBackoff:  time.Minute,
+}
+
+// retry - Checks if request should be retried after an error.
+func (policy *RetryPolicy) retry(req *Request, err error) bool {
+	if req.Attempt+1 >= policy.MaxAttempts {
+		return false
+	}
+	if policy.Retryable != nil {
+		return policy.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// backoff - Returns exponential backoff with jitter for an attempt.
+// Returned duration is between a half and a full backoff.
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	d := policy.MinBackoff
+	for i := 0; i < attempt && (policy.MaxBackoff <= 0 || d < policy.MaxBackoff); i++ {
+		d *= 2
+	}
+	if policy.MaxBackoff > 0 && d > policy.MaxBackoff {
+		d = policy.MaxBackoff
+	}
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half))
+	}
+	return d
+}
+
+// IsRetryable - Checks if error is retryable.
+// Network errors, timeouts, proxy bans, 5xx and 429 status errors are retryable.
+func IsRetryable(err error) bool {
+	switch err := err.(type) {
+	case *ProxyBanError:
+		return true
+	case *StatusError:
+		return isRetryableStatus(err.StatusCode)
+	case *url.Error:
+		if err.Timeout() {
+			return true
+		}
+		return IsRetryable(err.Err)
+	case net.Error:
+		return true
+	case interface{ Unwrap() error }:
+		// Typed crawl errors are retryable if underlying error is
+		return IsRetryable(err.Unwrap())
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+func isRetryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests
+}
@@ -0,0 +1,47 @@
+package crawl
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// TestIsRetryable -
+func TestIsRetryable(t *testing.T) {
+	for err, retryable := range map[error]bool{
+		&StatusError{StatusCode: 503}:                   true,
+		&StatusError{StatusCode: 429}:                   true,
+		&StatusError{StatusCode: 404}:                   false,
+		&url.Error{Op: "Get", Err: timeoutError{}}:      true,
+		&url.Error{Op: "Get", Err: errors.New("other")}: false,
+		&RobotsError{}:                                  false,
+	} {
+		if IsRetryable(err) != retryable {
+			t.Errorf("%v: expected retryable=%v", err, retryable)
+		}
+	}
+}
+
+// TestRetryPolicy -
+func TestRetryPolicy(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 2, MinBackoff: time.Second, MaxBackoff: 3 * time.Second}
+	err := &StatusError{StatusCode: 500}
+	if !policy.retry(&Request{}, err) {
+		t.Error("expected first attempt to be retried")
+	}
+	if policy.retry(&Request{Attempt: 1}, err) {
+		t.Error("expected last attempt not to be retried")
+	}
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if d := policy.backoff(attempt); d < max/2 || d > max {
+			t.Errorf("attempt %d: backoff %v out of range", attempt, d)
+		}
+	}
+}
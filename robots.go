@@ -0,0 +1,310 @@
+package crawl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// robotsMaxSize - Maximum size of robots.txt file that is read.
+const robotsMaxSize = 512 * 1024
+
+// RobotsError - Error returned when request is disallowed by robots.txt.
+type RobotsError struct {
+	URL       string
+	UserAgent string
+}
+
+// Error - Returns robots error message.
+func (err *RobotsError) Error() string {
+	return fmt.Sprintf("%s disallowed by robots.txt for %q", err.URL, err.UserAgent)
+}
+
+// robots - Fetches and caches robots.txt rules per host.
+type robots struct {
+	crawl *crawl
+	agent string
+
+	mutex *sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+// robotsEntry - Cached host robots.txt rules.
+// Ready channel is closed when rules are fetched.
+type robotsEntry struct {
+	ready chan struct{}
+	rules *robotsRules
+	err   error
+}
+
+func newRobots(c *crawl, agent string) *robots {
+	return &robots{
+		crawl: c,
+		agent: agent,
+		mutex: new(sync.Mutex),
+		hosts: make(map[string]*robotsEntry),
+	}
+}
+
+// Check - Checks if request URL is allowed by robots.txt.
+// Robots.txt is fetched through a proxy selected for the request.
+// Returns *RobotsError if it is disallowed.
+func (r *robots) Check(ctx context.Context, req *Request, u *url.URL) (err error) {
+	rules, err := r.rules(ctx, req, u)
+	if err != nil {
+		return
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if !rules.Allowed(path) {
+		return &RobotsError{URL: u.String(), UserAgent: r.agent}
+	}
+	return
+}
+
+// rules - Returns cached host rules or fetches them.
+// Fetch errors are not cached.
+func (r *robots) rules(ctx context.Context, req *Request, u *url.URL) (*robotsRules, error) {
+	key := u.Scheme + "://" + u.Host
+	r.mutex.Lock()
+	entry, ok := r.hosts[key]
+	if !ok {
+		entry = &robotsEntry{ready: make(chan struct{})}
+		r.hosts[key] = entry
+	}
+	r.mutex.Unlock()
+
+	if ok {
+		select {
+		case <-entry.ready:
+			return entry.rules, entry.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	entry.rules, entry.err = r.fetch(ctx, req, key)
+	if entry.err != nil {
+		r.mutex.Lock()
+		delete(r.hosts, key)
+		r.mutex.Unlock()
+	} else if entry.rules.delay > 0 && r.crawl.throttle != nil {
+		r.crawl.throttle.SetDelay(u.Host, entry.rules.delay)
+	}
+	close(entry.ready)
+	return entry.rules, entry.err
+}
+
+// fetch - Fetches robots.txt using crawler cache and a proxy
+// selected same as for the page request.
+// When robots.txt does not exist everything is allowed, also in offline
+// mode when it was not cached.
+func (r *robots) fetch(ctx context.Context, page *Request, base string) (_ *robotsRules, err error) {
+	req := &Request{URL: base + "/robots.txt"}
+	if ua, ok := r.crawl.opts.headers["User-Agent"]; ok {
+		req.Header = map[string]string{"User-Agent": ua}
+	}
+	httpReq, err := ConstructHTTPRequest(req)
+	if err != nil {
+		return
+	}
+	client, proxy, err := r.crawl.proxyClient(ctx, page, httpReq.URL.Host)
+	if err != nil {
+		return
+	}
+	resp, err := r.crawl.fetch(ctx, client, req, httpReq)
+	if err == ErrCacheMiss && r.crawl.opts.offline {
+		return new(robotsRules), nil
+	} else if err != nil {
+		if proxy != nil {
+			r.crawl.proxies.failure(proxy)
+		}
+		return
+	}
+	defer resp.Body.Close()
+	if proxy != nil {
+		r.crawl.proxies.success(proxy)
+	}
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return new(robotsRules), nil
+	default:
+		return nil, fmt.Errorf("robots.txt fetch error: %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, robotsMaxSize))
+	if err != nil {
+		return
+	}
+	return parseRobots(body, r.agent), nil
+}
+
+// robotsRules - Rules from robots.txt for a user-agent.
+type robotsRules struct {
+	rules []robotsRule
+	delay time.Duration
+}
+
+type robotsRule struct {
+	allow bool
+	path  string
+}
+
+// Allowed - Checks if path is allowed.
+// The longest matching rule wins, allow wins on equal length.
+func (rules *robotsRules) Allowed(path string) bool {
+	allow, length := true, -1
+	for _, rule := range rules.rules {
+		if !robotsMatch(rule.path, path) {
+			continue
+		}
+		if n := len(rule.path); n > length || (n == length && rule.allow) {
+			allow, length = rule.allow, n
+		}
+	}
+	return allow
+}
+
+// add - Adds rules from a group.
+func (rules *robotsRules) add(group *robotsGroup) {
+	rules.rules = append(rules.rules, group.rules...)
+	if group.delay > rules.delay {
+		rules.delay = group.delay
+	}
+}
+
+// robotsGroup - Group of robots.txt rules for a list of user-agents.
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+	delay  time.Duration
+}
+
+// parseRobots - Parses robots.txt and returns rules for user-agent.
+// Rules of all groups matching user-agent are merged, if none
+// is matching rules of "*" groups are used.
+func parseRobots(body []byte, agent string) *robotsRules {
+	var (
+		groups []*robotsGroup
+		group  *robotsGroup
+		inUA   bool
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		switch key {
+		case "user-agent":
+			if !inUA {
+				group = new(robotsGroup)
+				groups = append(groups, group)
+			}
+			group.agents = append(group.agents, strings.ToLower(value))
+			inUA = true
+			continue
+		case "allow", "disallow":
+			// Empty disallow allows everything
+			if group != nil && value != "" {
+				group.rules = append(group.rules, robotsRule{allow: key == "allow", path: value})
+			}
+		case "crawl-delay":
+			if group != nil {
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					group.delay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+		inUA = false
+	}
+
+	agent = strings.ToLower(agent)
+	specific, global := new(robotsRules), new(robotsRules)
+	matched := false
+	for _, group := range groups {
+		var isSpecific, isGlobal bool
+		for _, name := range group.agents {
+			if name == "*" {
+				isGlobal = true
+			} else if name != "" && strings.Contains(agent, name) {
+				isSpecific = true
+			}
+		}
+		if isSpecific {
+			specific.add(group)
+			matched = true
+		} else if isGlobal {
+			global.add(group)
+		}
+	}
+	if matched {
+		return specific
+	}
+	return global
+}
+
+// robotsSitemaps - Returns sitemaps URLs listed in robots.txt.
+func robotsSitemaps(body []byte) (sitemaps []string) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.Index(line, ":")
+		if i < 0 || !strings.EqualFold(strings.TrimSpace(line[:i]), "sitemap") {
+			continue
+		}
+		if value := strings.TrimSpace(line[i+1:]); value != "" {
+			sitemaps = append(sitemaps, value)
+		}
+	}
+	return
+}
+
+// robotsMatch - Matches robots.txt path pattern.
+// Supports "*" wildcard and "$" end anchor.
+func robotsMatch(pattern, path string) bool {
+	end := strings.HasSuffix(pattern, "$")
+	if end {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !end || path == ""
+	}
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(path, part)
+		if i < 0 {
+			return false
+		}
+		path = path[i+len(part):]
+	}
+	last := parts[len(parts)-1]
+	if end {
+		return strings.HasSuffix(path, last)
+	}
+	return strings.Contains(path, last)
+}
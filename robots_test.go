@@ -0,0 +1,155 @@
+package crawl
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var testRobots = []byte(`
+# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 1
+
+User-agent: crawlbot
+User-agent: otherbot
+Disallow: /
+Allow: /public
+Crawl-delay: 2.5
+`)
+
+// TestParseRobots -
+func TestParseRobots(t *testing.T) {
+	rules := parseRobots(testRobots, "Mozilla/5.0 (compatible; somebot)")
+	if rules.delay != time.Second {
+		t.Errorf("expected delay 1s, got %v", rules.delay)
+	}
+	for path, allowed := range map[string]bool{
+		"/":                   true,
+		"/private/":           false,
+		"/private/x":          false,
+		"/private/public/x":   true,
+		"/doc.pdf":            false,
+		"/doc.pdf?download=1": true,
+	} {
+		if rules.Allowed(path) != allowed {
+			t.Errorf("%s: expected allowed=%v", path, allowed)
+		}
+	}
+
+	rules = parseRobots(testRobots, "CrawlBot/1.0")
+	if rules.delay != 2500*time.Millisecond {
+		t.Errorf("expected delay 2.5s, got %v", rules.delay)
+	}
+	if rules.Allowed("/private/public") || !rules.Allowed("/public/x") {
+		t.Error("expected crawlbot group rules")
+	}
+}
+
+// TestRobotsOffline -
+func TestRobotsOffline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+			return
+		}
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "crawl-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// robots.txt is cached by online crawler and used offline
+	cache := NewFileCache(dir)
+	if _, err := New(WithCache(cache), WithRobots("crawlbot")).Execute(context.Background(), &Request{URL: ts.URL + "/public"}); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+
+	c := New(WithCache(cache), WithOffline(), WithRobots("crawlbot"))
+	_, err = c.Execute(context.Background(), &Request{URL: ts.URL + "/private/page"})
+	var robotsErr *RobotsError
+	if !errors.As(err, &robotsErr) {
+		t.Errorf("expected robots error, got %v", err)
+	}
+	if _, err := c.Execute(context.Background(), &Request{URL: ts.URL + "/public"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// TestRobotsDisallowed -
+func TestRobotsDisallowed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private/\nCrawl-delay: 2\n")
+			return
+		}
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer ts.Close()
+
+	c := New(WithRobots("crawlbot"))
+	_, err := c.Execute(context.Background(), &Request{URL: ts.URL + "/private/page"})
+	var robotsErr *RobotsError
+	if !errors.As(err, &robotsErr) || robotsErr.UserAgent != "crawlbot" {
+		t.Errorf("expected robots error, got %v", err)
+	}
+
+	// Crawl-delay is applied to the throttle of robots.txt host
+	th := c.(*crawl).throttle
+	th.mutex.Lock()
+	delay := th.delays[strings.TrimPrefix(ts.URL, "http://")]
+	th.mutex.Unlock()
+	if delay != 2*time.Second {
+		t.Errorf("expected crawl delay of 2s, got %v", delay)
+	}
+
+	// Disallowed queued request is sent to errors channel
+	c = New(WithRobots("crawlbot"))
+	c.Schedule(context.Background(), &Request{URL: ts.URL + "/private/page"})
+	if err := c.StartContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	err = <-c.Errors()
+	if reqErr, ok := err.(*RequestError); !ok || !errors.As(reqErr.Err, &robotsErr) {
+		t.Errorf("expected request error wrapping robots error, got %v", err)
+	}
+}
+
+// TestRobotsProxy -
+func TestRobotsProxy(t *testing.T) {
+	// Host is reachable only through the proxy
+	var robots bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.String() == "http://robots.invalid/robots.txt" {
+			robots = true
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		}
+	}))
+	defer proxy.Close()
+
+	c := New(WithRobots("crawlbot"), WithProxies(proxy.URL))
+	_, err := c.Execute(context.Background(), &Request{URL: "http://robots.invalid/private"})
+	var robotsErr *RobotsError
+	if !errors.As(err, &robotsErr) {
+		t.Errorf("expected robots error, got %v", err)
+	}
+	if !robots {
+		t.Error("expected robots.txt to be fetched through proxy")
+	}
+}
@@ -0,0 +1,280 @@
+package crawl
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// sitemapMaxSize - Maximum uncompressed size of sitemap file.
+const sitemapMaxSize = 50 * 1024 * 1024
+
+// sitemapMaxDepth - Maximum depth of nested sitemap indexes.
+const sitemapMaxDepth = 5
+
+// sitemapClient - Default sitemaps HTTP client.
+var sitemapClient = &http.Client{Timeout: time.Minute}
+
+// SitemapEntry - Entry of a sitemap or a sitemap index.
+type SitemapEntry struct {
+	Loc        string
+	LastMod    time.Time
+	ChangeFreq string
+	Priority   float64
+}
+
+// sitemapXML - Sitemap or sitemap index XML document.
+type sitemapXML struct {
+	XMLName  xml.Name
+	URLs     []sitemapEntryXML `xml:"url"`
+	Sitemaps []sitemapEntryXML `xml:"sitemap"`
+}
+
+type sitemapEntryXML struct {
+	Loc        string  `xml:"loc"`
+	LastMod    string  `xml:"lastmod"`
+	ChangeFreq string  `xml:"changefreq"`
+	Priority   float64 `xml:"priority"`
+}
+
+func (entry sitemapEntryXML) entry() SitemapEntry {
+	return SitemapEntry{
+		Loc:        strings.TrimSpace(entry.Loc),
+		LastMod:    parseLastMod(entry.LastMod),
+		ChangeFreq: strings.TrimSpace(entry.ChangeFreq),
+		Priority:   entry.Priority,
+	}
+}
+
+// lastModLayouts - W3C datetime layouts used in sitemaps.
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// parseLastMod - Parses lastmod, returns zero time if it is invalid.
+func parseLastMod(value string) (t time.Time) {
+	value = strings.TrimSpace(value)
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return
+}
+
+// ParseSitemap - Parses sitemap or sitemap index.
+// Gzip-compressed sitemaps are decompressed.
+// Returns page URLs entries and nested sitemaps entries.
+func ParseSitemap(r io.Reader) (urls, sitemaps []SitemapEntry, err error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+	doc := new(sitemapXML)
+	if err = xml.NewDecoder(io.LimitReader(r, sitemapMaxSize)).Decode(doc); err != nil {
+		return
+	}
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return nil, nil, fmt.Errorf("unexpected sitemap root element %q", doc.XMLName.Local)
+	}
+	for _, entry := range doc.URLs {
+		if entry := entry.entry(); entry.Loc != "" {
+			urls = append(urls, entry)
+		}
+	}
+	for _, entry := range doc.Sitemaps {
+		if entry := entry.entry(); entry.Loc != "" {
+			sitemaps = append(sitemaps, entry)
+		}
+	}
+	return
+}
+
+// Sitemaps - Sitemaps crawler used to seed a crawl.
+type Sitemaps struct {
+	// Client - HTTP client used to fetch sitemaps.
+	// Default: client with 1 minute timeout.
+	Client *http.Client
+
+	// UserAgent - User-Agent header of sitemaps requests.
+	UserAgent string
+
+	// Since - Entries modified before are skipped.
+	// Entries without lastmod are never skipped.
+	// It applies to nested sitemaps as well.
+	Since time.Time
+
+	// Allow - URL is scheduled only if it matches any of expressions.
+	Allow []*regexp.Regexp
+	// Deny - URL is not scheduled if it matches any of expressions.
+	Deny []*regexp.Regexp
+
+	// Request - Template of scheduled requests, eg. with callbacks.
+	// It is copied for every entry and URL is set to entry location.
+	Request *Request
+}
+
+// Discover - Returns sitemaps listed in robots.txt of a site.
+// If robots.txt has none it returns /sitemap.xml of the site.
+func (s *Sitemaps) Discover(ctx context.Context, site string) (sitemaps []string, err error) {
+	u, err := url.Parse(site)
+	if err != nil {
+		return
+	}
+	base := u.Scheme + "://" + u.Host
+	body, err := s.get(ctx, base+"/robots.txt", robotsMaxSize)
+	if err != nil {
+		if _, ok := err.(*StatusError); !ok {
+			return
+		}
+	}
+	if sitemaps = robotsSitemaps(body); len(sitemaps) == 0 {
+		sitemaps = []string{base + "/sitemap.xml"}
+	}
+	return sitemaps, nil
+}
+
+// Walk - Fetches sitemap and calls fn for every page URL entry
+// which is not filtered. Nested sitemap indexes are followed.
+// Sitemaps which could not be fetched or parsed are skipped and their
+// errors are returned joined when walking is finished.
+// Walking is stopped when fn returns an error and it is returned.
+func (s *Sitemaps) Walk(ctx context.Context, sitemap string, fn func(SitemapEntry) error) error {
+	var errs []error
+	if err := s.walk(ctx, sitemap, 0, make(map[string]bool), fn, &errs); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// walk - Walks sitemap and its nested sitemaps.
+// Sitemap errors are appended to errs, only fn error is returned.
+func (s *Sitemaps) walk(ctx context.Context, sitemap string, depth int, visited map[string]bool, fn func(SitemapEntry) error, errs *[]error) (err error) {
+	if visited[sitemap] || depth > sitemapMaxDepth {
+		return
+	}
+	visited[sitemap] = true
+	body, err := s.get(ctx, sitemap, sitemapMaxSize)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("sitemap %s: %w", sitemap, err))
+		return nil
+	}
+	urls, sitemaps, err := ParseSitemap(bytes.NewReader(body))
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("sitemap %s: %w", sitemap, err))
+		return nil
+	}
+	for _, entry := range urls {
+		if !s.allowed(entry) {
+			continue
+		}
+		if err = fn(entry); err != nil {
+			return
+		}
+	}
+	for _, entry := range sitemaps {
+		if !entry.LastMod.IsZero() && entry.LastMod.Before(s.Since) {
+			continue
+		}
+		if err = s.walk(ctx, entry.Loc, depth+1, visited, fn, errs); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Schedule - Walks sitemaps and schedules requests for all entries.
+// Returns number of scheduled requests. Sitemaps errors are returned
+// joined after walking all sitemaps, scheduling is stopped on
+// the first scheduler error.
+func (s *Sitemaps) Schedule(ctx context.Context, scheduler Scheduler, sitemaps ...string) (n int, err error) {
+	var errs []error
+	for _, sitemap := range sitemaps {
+		var scheduleErr error
+		err = s.Walk(ctx, sitemap, func(entry SitemapEntry) error {
+			if scheduleErr = scheduler.Schedule(ctx, s.request(entry)); scheduleErr != nil {
+				return scheduleErr
+			}
+			n++
+			return nil
+		})
+		if scheduleErr != nil {
+			return n, scheduleErr
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+// request - Returns copy of request template for an entry.
+func (s *Sitemaps) request(entry SitemapEntry) *Request {
+	req := new(Request)
+	if s.Request != nil {
+		*req = *s.Request
+	}
+	req.URL = entry.Loc
+	return req
+}
+
+func (s *Sitemaps) allowed(entry SitemapEntry) bool {
+	if !entry.LastMod.IsZero() && entry.LastMod.Before(s.Since) {
+		return false
+	}
+	if len(s.Allow) > 0 && !matchAny(entry.Loc, s.Allow) {
+		return false
+	}
+	return !matchAny(entry.Loc, s.Deny)
+}
+
+// get - Fetches body of a URL limited to size.
+// Returns *StatusError if response status code is not 2xx.
+func (s *Sitemaps) get(ctx context.Context, u string, size int64) (_ []byte, err error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return
+	}
+	if s.UserAgent != "" {
+		req.Header.Set("User-Agent", s.UserAgent)
+	}
+	client := s.Client
+	if client == nil {
+		client = sitemapClient
+	}
+	resp, err := ctxhttp.Do(ctx, client, req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, size))
+}
@@ -0,0 +1,94 @@
+package crawl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// TestSitemaps -
+func TestSitemaps(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /private\nSitemap: %s/index.xml\n", ts.URL)
+		case "/index.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>%[1]s/missing.xml</loc></sitemap>
+	<sitemap><loc>%[1]s/pages.xml.gz</loc><lastmod>2020-05-01</lastmod></sitemap>
+	<sitemap><loc>%[1]s/old.xml</loc><lastmod>2010-01-01</lastmod></sitemap>
+	<sitemap><loc>%[1]s/index.xml</loc></sitemap>
+</sitemapindex>`, ts.URL)
+		case "/pages.xml.gz":
+			gz := gzip.NewWriter(w)
+			fmt.Fprintf(gz, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>%[1]s/item/1</loc><lastmod>2020-04-01T10:00:00+00:00</lastmod></url>
+	<url><loc>%[1]s/item/2</loc><lastmod>2015-01-01</lastmod></url>
+	<url><loc>%[1]s/item/3</loc></url>
+	<url><loc>%[1]s/tag/3</loc></url>
+</urlset>`, ts.URL)
+			gz.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	s := &Sitemaps{
+		Since:   time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		Deny:    []*regexp.Regexp{regexp.MustCompile(`/tag/`)},
+		Request: &Request{Callbacks: Callbacks("item"), Proxy: "http://127.0.0.1:8080", Timeout: time.Minute},
+	}
+	sitemaps, err := s.Discover(context.Background(), ts.URL+"/some/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sitemaps, []string{ts.URL + "/index.xml"}) {
+		t.Fatalf("unexpected sitemaps %q", sitemaps)
+	}
+
+	// Missing nested sitemap is reported and other sitemaps are walked
+	queue := NewQueue(10)
+	n, err := s.Schedule(context.Background(), queue, sitemaps...)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || !strings.Contains(err.Error(), "/missing.xml") {
+		t.Errorf("expected missing sitemap error, got %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 requests, got %d", n)
+	}
+	for _, path := range []string{"/item/1", "/item/3"} {
+		job, _ := queue.Get()
+		req := job.Request()
+		if req.URL != ts.URL+path || !reflect.DeepEqual(req.Callbacks, Callbacks("item")) || req.Proxy != s.Request.Proxy || req.Timeout != time.Minute {
+			t.Errorf("unexpected request %+v", req)
+		}
+	}
+}
+
+// TestParseSitemap -
+func TestParseSitemap(t *testing.T) {
+	if _, _, err := ParseSitemap(bytes.NewBufferString("<html></html>")); err == nil {
+		t.Error("expected error on html document")
+	}
+	urls, _, err := ParseSitemap(bytes.NewBufferString(`<urlset><url><loc> http://a.com/ </loc><priority>0.5</priority><lastmod>2020-01-02T03:04Z</lastmod></url></urlset>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []SitemapEntry{{Loc: "http://a.com/", Priority: 0.5, LastMod: time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)}}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("expected %+v, got %+v", expected, urls)
+	}
+}
@@ -0,0 +1,149 @@
+package crawl
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets - Upper bounds of latency histogram buckets in seconds.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Stats - Crawler statistics snapshot.
+// Requests counters include only requests executed from the queue.
+type Stats struct {
+	// Scheduled - Number of requests scheduled using crawler.
+	Scheduled int64
+	// Started - Number of requests taken from the queue and started.
+	Started int64
+	// Completed - Number of requests executed without an error.
+	Completed int64
+	// Failed - Number of requests failed after the last attempt.
+	Failed int64
+	// Retried - Number of retries scheduled.
+	Retried int64
+	// Panics - Number of recovered panics.
+	Panics int64
+
+	// BytesDownloaded - Number of response body bytes read.
+	BytesDownloaded int64
+	// StatusCodes - Number of responses by status code.
+	StatusCodes map[int]int64
+
+	// HostLatency - Latency of receiving response headers per host.
+	HostLatency map[string]*Histogram
+	// CallbackLatency - Handlers execution time per callback.
+	CallbackLatency map[string]*Histogram
+
+	// QueueDepth - Number of requests waiting in the queue,
+	// -1 if queue does not implement LenQueue.
+	QueueDepth int
+	// InFlight - Number of requests being executed by workers.
+	InFlight int64
+}
+
+// Histogram - Latency histogram.
+type Histogram struct {
+	// Buckets - Upper bounds of buckets in seconds.
+	Buckets []float64
+	// Counts - Cumulative number of observations per bucket.
+	Counts []int64
+	// Count - Number of all observations.
+	Count int64
+	// Sum - Sum of all observations in seconds.
+	Sum float64
+}
+
+// newHistogram - Creates histogram with DefaultLatencyBuckets.
+func newHistogram() *Histogram {
+	return &Histogram{
+		Buckets: DefaultLatencyBuckets,
+		Counts:  make([]int64, len(DefaultLatencyBuckets)),
+	}
+}
+
+// Observe - Adds observation to histogram.
+func (h *Histogram) Observe(d time.Duration) {
+	v := d.Seconds()
+	for i, bound := range h.Buckets {
+		if v <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += v
+}
+
+func (h *Histogram) copy() *Histogram {
+	c := *h
+	c.Counts = append([]int64(nil), h.Counts...)
+	return &c
+}
+
+// stats - Crawler statistics collector.
+type stats struct {
+	mutex *sync.Mutex
+	stats Stats
+}
+
+func newStats() *stats {
+	return &stats{
+		mutex: new(sync.Mutex),
+		stats: Stats{
+			StatusCodes:     make(map[int]int64),
+			HostLatency:     make(map[string]*Histogram),
+			CallbackLatency: make(map[string]*Histogram),
+		},
+	}
+}
+
+// add - Updates statistics under lock.
+func (s *stats) add(fn func(*Stats)) {
+	s.mutex.Lock()
+	fn(&s.stats)
+	s.mutex.Unlock()
+}
+
+// response - Records response status code and host latency.
+func (s *stats) response(host string, code int, latency time.Duration) {
+	s.add(func(stats *Stats) {
+		stats.StatusCodes[code]++
+		h, ok := stats.HostLatency[host]
+		if !ok {
+			h = newHistogram()
+			stats.HostLatency[host] = h
+		}
+		h.Observe(latency)
+	})
+}
+
+// handler - Records callback handlers execution time.
+func (s *stats) handler(callback string, latency time.Duration) {
+	s.add(func(stats *Stats) {
+		h, ok := stats.CallbackLatency[callback]
+		if !ok {
+			h = newHistogram()
+			stats.CallbackLatency[callback] = h
+		}
+		h.Observe(latency)
+	})
+}
+
+// snapshot - Returns a copy of statistics.
+func (s *stats) snapshot() *Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := s.stats
+	snapshot.StatusCodes = make(map[int]int64, len(s.stats.StatusCodes))
+	for code, n := range s.stats.StatusCodes {
+		snapshot.StatusCodes[code] = n
+	}
+	snapshot.HostLatency = make(map[string]*Histogram, len(s.stats.HostLatency))
+	for host, h := range s.stats.HostLatency {
+		snapshot.HostLatency[host] = h.copy()
+	}
+	snapshot.CallbackLatency = make(map[string]*Histogram, len(s.stats.CallbackLatency))
+	for callback, h := range s.stats.CallbackLatency {
+		snapshot.CallbackLatency[callback] = h.copy()
+	}
+	return &snapshot
+}
@@ -0,0 +1,65 @@
+package crawl
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// TestStats -
+func TestStats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<html>hello</html>"))
+	}))
+	defer ts.Close()
+
+	c := New()
+	c.Register("page", func(context.Context, *Response) error { return nil })
+	c.Schedule(context.Background(), &Request{URL: ts.URL, Callbacks: Callbacks("page")})
+	c.Schedule(context.Background(), &Request{URL: ts.URL + "/missing"})
+	c.Schedule(context.Background(), &Request{URL: "http://127.0.0.1:1/"})
+	if depth := c.Stats().QueueDepth; depth != 3 {
+		t.Errorf("expected queue depth 3, got %d", depth)
+	}
+	c.Start()
+
+	stats := c.Stats()
+	if stats.Scheduled != 3 || stats.Started != 3 || stats.Completed != 2 || stats.Failed != 1 || stats.InFlight != 0 {
+		t.Errorf("unexpected counters %+v", stats)
+	}
+	if stats.StatusCodes[200] != 1 || stats.StatusCodes[404] != 1 {
+		t.Errorf("unexpected status codes %v", stats.StatusCodes)
+	}
+	if stats.BytesDownloaded < 18 {
+		t.Errorf("expected at least 18 bytes downloaded, got %d", stats.BytesDownloaded)
+	}
+	host := strings.TrimPrefix(ts.URL, "http://")
+	if h := stats.HostLatency[host]; h == nil || h.Count != 2 {
+		t.Errorf("unexpected host latency %+v", h)
+	}
+	if h := stats.CallbackLatency["page"]; h == nil || h.Count != 1 {
+		t.Errorf("unexpected callback latency %+v", h)
+	}
+
+	w := new(bytes.Buffer)
+	WriteMetrics(w, stats)
+	for _, line := range []string{
+		"crawl_requests_completed_total 2\n",
+		"crawl_responses_total{code=\"404\"} 1\n",
+		"crawl_response_duration_seconds_count{host=\"" + host + "\"} 2\n",
+		"crawl_handler_duration_seconds_bucket{callback=\"page\",le=\"+Inf\"} 1\n",
+		"crawl_queue_depth 0\n",
+	} {
+		if !strings.Contains(w.String(), line) {
+			t.Errorf("metrics do not contain %q", line)
+		}
+	}
+}
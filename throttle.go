@@ -0,0 +1,230 @@
+package crawl
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// throttle - Per-host politeness scheduler.
+// Jobs are added after they are received from the queue and sent to
+// workers on ready channel when host limits allow it. Jobs waiting
+// for a throttled host are held in memory and do not block workers.
+// Number of held jobs is limited, Add blocks when the limit is reached
+// so remaining jobs are kept in the queue.
+type throttle struct {
+	ready chan Job
+
+	// maxInFlight - Maximum number of in-flight requests per host.
+	// Zero means no limit.
+	maxInFlight int
+	// delay - Minimum delay between requests to the same host.
+	delay time.Duration
+	// jitter - Maximum random duration added to the delay.
+	jitter time.Duration
+	// maxHostWaiting - Maximum number of jobs waiting for a host.
+	// Zero means no limit.
+	maxHostWaiting int
+	// maxWaiting - Maximum number of jobs waiting for all hosts.
+	// Zero means no limit.
+	maxWaiting int
+
+	mutex *sync.Mutex
+	// cond - Signaled when waiting jobs are sent or dropped.
+	cond  *sync.Cond
+	hosts map[string]*hostState
+	// delays - Per-host delays overriding default delay.
+	delays map[string]time.Duration
+	// pending - Number of jobs not yet sent to workers.
+	pending *sync.WaitGroup
+	// waiting - Number of jobs waiting for host limits.
+	waiting int
+	// stopped - When true jobs are dropped instead of being throttled.
+	stopped bool
+}
+
+// hostState - Throttle state of a single host.
+type hostState struct {
+	inFlight int
+	next     time.Time
+	waiting  bool
+	jobs     []Job
+}
+
+func newThrottle(maxInFlight int, delay, jitter time.Duration) *throttle {
+	mutex := new(sync.Mutex)
+	return &throttle{
+		ready:          make(chan Job),
+		maxInFlight:    maxInFlight,
+		delay:          delay,
+		jitter:         jitter,
+		maxHostWaiting: 1,
+		mutex:          mutex,
+		cond:           sync.NewCond(mutex),
+		hosts:          make(map[string]*hostState),
+		delays:         make(map[string]time.Duration),
+		pending:        new(sync.WaitGroup),
+	}
+}
+
+// Add - Adds a job to host queue.
+// It is sent to ready channel when host limits allow it.
+// Job is dropped if throttle was stopped.
+// It blocks until host and throttle are below waiting jobs limits.
+func (t *throttle) Add(job Job) {
+	host := requestHost(job.Request())
+	t.mutex.Lock()
+	if t.stopped {
+		t.mutex.Unlock()
+		return
+	}
+	t.pending.Add(1)
+	state, ok := t.hosts[host]
+	if !ok {
+		state = new(hostState)
+		t.hosts[host] = state
+	}
+	state.jobs = append(state.jobs, job)
+	t.waiting++
+	jobs := t.dispatch(host, state)
+	t.mutex.Unlock()
+	t.send(jobs)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for !t.stopped && t.full(state) {
+		t.cond.Wait()
+	}
+}
+
+// full - Checks if host or throttle reached waiting jobs limit.
+// Mutex has to be locked.
+func (t *throttle) full(state *hostState) bool {
+	return (t.maxHostWaiting > 0 && len(state.jobs) >= t.maxHostWaiting) ||
+		(t.maxWaiting > 0 && t.waiting >= t.maxWaiting)
+}
+
+// Done - Marks job as done releasing its host slot.
+func (t *throttle) Done(job Job) {
+	host := requestHost(job.Request())
+	t.mutex.Lock()
+	state, ok := t.hosts[host]
+	if !ok {
+		t.mutex.Unlock()
+		return
+	}
+	state.inFlight--
+	jobs := t.dispatch(host, state)
+	if state.inFlight == 0 && len(state.jobs) == 0 && !time.Now().Before(state.next) {
+		delete(t.hosts, host)
+	}
+	t.mutex.Unlock()
+	// Jobs are sent in background because Done is called by a worker
+	// and all workers can be busy marking jobs as done at the same time.
+	go t.send(jobs)
+}
+
+// Wait - Waits until all added jobs are sent to workers.
+func (t *throttle) Wait() {
+	t.pending.Wait()
+}
+
+// Stop - Drops jobs waiting for host limits and jobs added later.
+// Dropped jobs are not marked as done, same as jobs skipped by workers
+// when crawler is stopping.
+func (t *throttle) Stop() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.stopped = true
+	for _, state := range t.hosts {
+		for range state.jobs {
+			t.pending.Done()
+		}
+		state.jobs = nil
+	}
+	t.waiting = 0
+	t.cond.Broadcast()
+}
+
+// SetDelay - Sets minimum delay between requests to a host.
+// It overrides default delay if it is longer.
+func (t *throttle) SetDelay(host string, d time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if d > t.delay {
+		t.delays[host] = d
+	} else {
+		delete(t.delays, host)
+	}
+}
+
+// dispatch - Takes jobs that can be started now.
+// If host delay didn't pass yet it sets a timer.
+// Mutex has to be locked.
+func (t *throttle) dispatch(host string, state *hostState) (jobs []Job) {
+	for len(state.jobs) > 0 {
+		if t.maxInFlight > 0 && state.inFlight >= t.maxInFlight {
+			return
+		}
+		now := time.Now()
+		if wait := state.next.Sub(now); wait > 0 {
+			if !state.waiting {
+				state.waiting = true
+				time.AfterFunc(wait, func() { t.wakeup(host) })
+			}
+			return
+		}
+		jobs = append(jobs, state.jobs[0])
+		state.jobs = state.jobs[1:]
+		state.inFlight++
+		state.next = now.Add(t.hostDelay(host))
+		t.waiting--
+		t.cond.Broadcast()
+	}
+	return
+}
+
+// wakeup - Dispatches jobs after host delay has passed.
+func (t *throttle) wakeup(host string) {
+	t.mutex.Lock()
+	state, ok := t.hosts[host]
+	if !ok {
+		t.mutex.Unlock()
+		return
+	}
+	state.waiting = false
+	jobs := t.dispatch(host, state)
+	t.mutex.Unlock()
+	t.send(jobs)
+}
+
+// send - Sends jobs to workers.
+func (t *throttle) send(jobs []Job) {
+	for _, job := range jobs {
+		t.ready <- job
+		t.pending.Done()
+	}
+}
+
+// hostDelay - Returns host delay with random jitter.
+// Mutex has to be locked.
+func (t *throttle) hostDelay(host string) time.Duration {
+	d, ok := t.delays[host]
+	if !ok {
+		d = t.delay
+	}
+	if t.jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(t.jitter)))
+	}
+	return d
+}
+
+// requestHost - Returns request URL host.
+// Returns empty string if URL could not be parsed.
+func requestHost(req *Request) string {
+	u, err := req.ParseURL()
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
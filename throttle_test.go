@@ -0,0 +1,119 @@
+package crawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func throttleJob(u string) Job {
+	return &memJob{ctx: context.Background(), req: &Request{URL: u}}
+}
+
+// TestThrottleHostConcurrency -
+func TestThrottleHostConcurrency(t *testing.T) {
+	th := newThrottle(1, 0, 0)
+	go th.Add(throttleJob("http://a.com/1"))
+	go th.Add(throttleJob("http://a.com/2"))
+	<-th.ready
+	// Other host is not blocked by a.com
+	go th.Add(throttleJob("http://b.com/1"))
+	select {
+	case job := <-th.ready:
+		if job.Request().URL != "http://b.com/1" {
+			t.Fatalf("expected b.com job, got %v", job.Request())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("b.com job was blocked")
+	}
+}
+
+// TestThrottleHostDelay -
+func TestThrottleHostDelay(t *testing.T) {
+	th := newThrottle(0, 50*time.Millisecond, 0)
+	go th.Add(throttleJob("http://a.com/1"))
+	go th.Add(throttleJob("http://a.com/2"))
+	start := time.Now()
+	<-th.ready
+	<-th.ready
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected delay between requests, got %v", elapsed)
+	}
+	th.Wait()
+}
+
+// TestThrottleStop -
+func TestThrottleStop(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	c := New(WithHostDelay(200 * time.Millisecond))
+	started := make(chan bool, 20)
+	c.Register("page", func(context.Context, *Response) error {
+		started <- true
+		return nil
+	})
+	for i := 0; i < 20; i++ {
+		c.Schedule(context.Background(), &Request{URL: fmt.Sprintf("%s/%d", ts.URL, i), Callbacks: Callbacks("page")})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.StartContext(ctx) }()
+	<-started
+
+	// Pending jobs are dropped instead of being paced by host delay
+	start := time.Now()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("throttled crawler did not stop")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected crawler to stop promptly, took %v", elapsed)
+	}
+	if len(started) > 2 {
+		t.Errorf("expected pending jobs to be dropped, %d were started", len(started))
+	}
+}
+
+// TestThrottlePriority -
+func TestThrottlePriority(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	c := New(WithHostDelay(100*time.Millisecond), WithQueue(NewPriorityQueue(100)))
+	var (
+		mutex = new(sync.Mutex)
+		paths []string
+	)
+	c.Register("page", func(ctx context.Context, resp *Response) error {
+		mutex.Lock()
+		paths = append(paths, resp.URL().Path)
+		mutex.Unlock()
+		// Jobs which were not read by throttle stay in the queue
+		if resp.URL().Path == "/low0" {
+			return c.Schedule(ctx, &Request{URL: ts.URL + "/high", Callbacks: Callbacks("page"), Priority: 100})
+		}
+		return nil
+	})
+	for i := 0; i < 5; i++ {
+		c.Schedule(context.Background(), &Request{URL: fmt.Sprintf("%s/low%d", ts.URL, i), Callbacks: Callbacks("page")})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.StartContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/low0", "/low1", "/high", "/low2", "/low3", "/low4"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+}
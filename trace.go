@@ -0,0 +1,233 @@
+package crawl
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http/httptrace"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// TraceContext - Trace context propagated between requests.
+// Requests scheduled from handlers are children of handler span.
+type TraceContext struct {
+	TraceID string `json:"trace_id"`
+	// SpanID - Parent span ID, empty for a root of a trace.
+	SpanID string `json:"span_id,omitempty"`
+}
+
+// NewTraceContext - Creates context of a new trace.
+func NewTraceContext() *TraceContext {
+	return &TraceContext{TraceID: newTraceID(16)}
+}
+
+// traceKey - Context key of trace context.
+type traceKey struct{}
+
+// WithTrace - Sets trace context in context.
+func WithTrace(ctx context.Context, trace *TraceContext) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFromContext - Returns trace context from context.
+func TraceFromContext(ctx context.Context) (trace *TraceContext, ok bool) {
+	trace, ok = ctx.Value(traceKey{}).(*TraceContext)
+	return
+}
+
+// Span - Traced operation.
+// All methods are safe to call on nil span.
+type Span struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	Name       string                 `json:"name"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+
+	mutex *sync.Mutex
+}
+
+// newSpan - Starts a span, it is a root of a new trace if parent is nil.
+func newSpan(parent *TraceContext, name string) *Span {
+	span := &Span{
+		SpanID: newTraceID(8),
+		Name:   name,
+		Start:  time.Now(),
+		mutex:  new(sync.Mutex),
+	}
+	if parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		span.TraceID = newTraceID(16)
+	}
+	return span
+}
+
+// Context - Returns trace context with span as a parent.
+func (span *Span) Context() *TraceContext {
+	if span == nil {
+		return nil
+	}
+	return &TraceContext{TraceID: span.TraceID, SpanID: span.SpanID}
+}
+
+// SetAttribute - Sets span attribute.
+func (span *Span) SetAttribute(key string, value interface{}) {
+	if span == nil {
+		return
+	}
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	if span.Attributes == nil {
+		span.Attributes = make(map[string]interface{})
+	}
+	span.Attributes[key] = value
+}
+
+// finish - Sets span end time and error.
+func (span *Span) finish(err error) {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.End = time.Now()
+	if err != nil {
+		span.Error = err.Error()
+	}
+}
+
+// SpanExporter - Exports finished spans.
+type SpanExporter interface {
+	ExportSpan(*Span) error
+}
+
+// JSONSpanExporter - Writes spans as JSON lines.
+type JSONSpanExporter struct {
+	mutex   *sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewJSONSpanExporter - Creates exporter writing spans as JSON lines.
+func NewJSONSpanExporter(w io.Writer) *JSONSpanExporter {
+	return &JSONSpanExporter{mutex: new(sync.Mutex), encoder: json.NewEncoder(w)}
+}
+
+// NewFileSpanExporter - Creates exporter appending spans
+// as JSON lines to a file. It has to be closed using Close().
+func NewFileSpanExporter(path string) (*JSONSpanExporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	exporter := NewJSONSpanExporter(f)
+	exporter.closer = f
+	return exporter, nil
+}
+
+// ExportSpan - Writes span as a JSON line.
+func (exporter *JSONSpanExporter) ExportSpan(span *Span) error {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	return exporter.encoder.Encode(span)
+}
+
+// Close - Closes exporter file if any.
+func (exporter *JSONSpanExporter) Close() error {
+	if exporter.closer == nil {
+		return nil
+	}
+	return exporter.closer.Close()
+}
+
+// startSpan - Starts a span if tracing is enabled, otherwise returns nil.
+func (crawl *crawl) startSpan(parent *TraceContext, name string) *Span {
+	if crawl.spans == nil {
+		return nil
+	}
+	return newSpan(parent, name)
+}
+
+// endSpan - Finishes and exports a span.
+// Export errors are logged.
+func (crawl *crawl) endSpan(span *Span, err error) {
+	if span == nil {
+		return
+	}
+	span.finish(err)
+	if err := crawl.spans.ExportSpan(span); err != nil {
+		crawl.logger.Log(LevelWarning, "span export error", F("error", err))
+	}
+}
+
+// clientTrace - Returns http client trace creating spans
+// for DNS lookup, connect, TLS handshake and first response byte.
+func (crawl *crawl) clientTrace(parent *Span) *httptrace.ClientTrace {
+	var (
+		mutex          sync.Mutex
+		dns, handshake *Span
+		connects       = make(map[string]*Span)
+	)
+	start := time.Now()
+	ctx := parent.Context()
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			dns = crawl.startSpan(ctx, "dns")
+			dns.SetAttribute("host", info.Host)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			crawl.endSpan(dns, info.Err)
+		},
+		ConnectStart: func(network, addr string) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			span := crawl.startSpan(ctx, "connect")
+			span.SetAttribute("addr", addr)
+			connects[network+addr] = span
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			crawl.endSpan(connects[network+addr], err)
+		},
+		TLSHandshakeStart: func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			handshake = crawl.startSpan(ctx, "tls")
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			crawl.endSpan(handshake, err)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			parent.SetAttribute("conn_reused", info.Reused)
+		},
+		GotFirstResponseByte: func() {
+			span := crawl.startSpan(ctx, "first_byte")
+			span.Start = start
+			crawl.endSpan(span, nil)
+		},
+	}
+}
+
+// newTraceID - Returns random hex encoded ID of n bytes.
+func newTraceID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
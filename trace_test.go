@@ -0,0 +1,65 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// TestTrace -
+func TestTrace(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+
+	out := new(bytes.Buffer)
+	c := New(WithSpanExporter(NewJSONSpanExporter(out)))
+	c.Register("seed", func(ctx context.Context, resp *Response) error {
+		return c.Schedule(ctx, &Request{URL: ts.URL + "/page", Callbacks: Callbacks("page")})
+	})
+	c.Register("page", func(context.Context, *Response) error { return nil })
+	trace := NewTraceContext()
+	c.Schedule(WithTrace(context.Background(), trace), &Request{URL: ts.URL, Callbacks: Callbacks("seed")})
+	c.Start()
+
+	spans := make(map[string]*Span)
+	byName := make(map[string][]*Span)
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		span := new(Span)
+		if err := decoder.Decode(span); err != nil {
+			t.Fatal(err)
+		}
+		if span.TraceID != trace.TraceID {
+			t.Errorf("span %s has trace ID %s", span.Name, span.TraceID)
+		}
+		spans[span.SpanID] = span
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+	if len(byName["crawl.request"]) != 2 || len(byName["crawl.handler"]) != 2 || len(byName["first_byte"]) != 2 {
+		t.Fatalf("unexpected spans %v", byName)
+	}
+	if len(byName["connect"]) == 0 {
+		t.Error("expected connect span")
+	}
+
+	// seed request -> seed handler -> page request -> page handler
+	var page *Span
+	for _, span := range byName["crawl.request"] {
+		if span.Attributes["url"] == ts.URL+"/page" {
+			page = span
+		}
+	}
+	handler := spans[page.ParentID]
+	if handler == nil || handler.Attributes["callback"] != "seed" {
+		t.Fatalf("expected page request to be a child of seed handler, got %+v", handler)
+	}
+	if seed := spans[handler.ParentID]; seed == nil || seed.ParentID != "" || seed.Attributes["url"] != ts.URL {
+		t.Errorf("expected seed handler to be a child of seed request, got %+v", seed)
+	}
+}